@@ -0,0 +1,136 @@
+package onc
+
+import (
+	"math/big"
+	"net"
+)
+
+// AddressBreakdown accounts for the addresses of a network: Allocatable is
+// Total minus every Reserved entry.
+type AddressBreakdown struct {
+	Total       *big.Int      `json:"total"`
+	Reserved    []Reservation `json:"reserved"`
+	Allocatable *big.Int      `json:"allocatable"`
+}
+
+type Reservation struct {
+	Reason string      `json:"reason"`
+	Role   AddressRole `json:"role"`
+	// Offset locates a fixed address from the start of the subnet, or from
+	// its end when negative (-1 is the last address). It is nil for
+	// addresses allocated anywhere in the subnet and for totals.
+	Offset *int     `json:"offset,omitempty"`
+	Count  *big.Int `json:"count"`
+}
+
+type AddressRole string
+
+const (
+	AddressNetwork        AddressRole = "network"
+	AddressBroadcast      AddressRole = "broadcast"
+	AddressGateway        AddressRole = "gateway"
+	AddressManagementPort AddressRole = "management-port"
+	AddressHybridOverlay  AddressRole = "hybrid-overlay"
+	AddressTunnel         AddressRole = "tunnel"
+	AddressRouter         AddressRole = "router"
+	AddressHealth         AddressRole = "health"
+	AddressIngress        AddressRole = "ingress"
+	AddressService        AddressRole = "service"
+	AddressUnusable       AddressRole = "unusable"
+	AddressPod            AddressRole = "pod"
+)
+
+// AddressAccounting breaks down the pod, service and machine networks of a
+// family. Pod totals every cluster network entry; the per-node breakdown is
+// on each ClusterNetworkResult.
+type AddressAccounting struct {
+	Pod     AddressBreakdown `json:"pod"`
+	Service AddressBreakdown `json:"service"`
+	Machine AddressBreakdown `json:"machine"`
+}
+
+// serviceIPAllocatorMax is the most addresses the Kubernetes service IP
+// allocator uses from an IPv6 range; it keeps a bitmap of this size.
+var serviceIPAllocatorMax = big.NewInt(65536)
+
+// reserve reserves one address that may be anywhere in the subnet.
+func reserve(role AddressRole, reason string) Reservation {
+	return Reservation{Reason: reason, Role: role, Count: big.NewInt(1)}
+}
+
+// reserveAt reserves the address at offset; see Reservation.Offset.
+func reserveAt(role AddressRole, reason string, offset int) Reservation {
+	reservation := reserve(role, reason)
+	reservation.Offset = &offset
+	return reservation
+}
+
+// subnetEdges reserves the network address and, for IPv4, the broadcast
+// address of a subnet.
+func subnetEdges(family IPFamily) []Reservation {
+	if family == IPv6 {
+		return []Reservation{reserveAt(AddressNetwork, "subnet-router anycast address", 0)}
+	}
+	return []Reservation{reserveAt(AddressNetwork, "network address", 0), reserveAt(AddressBroadcast, "broadcast address", -1)}
+}
+
+func newAddressBreakdown(total *big.Int, reserved []Reservation) AddressBreakdown {
+	allocatable := new(big.Int).Set(total)
+	for _, reservation := range reserved {
+		allocatable.Sub(allocatable, reservation.Count)
+	}
+	if allocatable.Sign() < 0 {
+		allocatable.SetInt64(0)
+	}
+	if reserved == nil {
+		reserved = []Reservation{}
+	}
+	return AddressBreakdown{Total: total, Reserved: reserved, Allocatable: allocatable}
+}
+
+func cidrSize(ipNet *net.IPNet) *big.Int {
+	ones, bits := ipNet.Mask.Size()
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+}
+
+// serviceAddresses follows the Kubernetes service IP allocator, which skips
+// the network address (and the IPv4 broadcast address) and caps IPv6 ranges.
+// The kubernetes API and cluster DNS services take fixed addresses.
+func serviceAddresses(family IPFamily, ipNet *net.IPNet) AddressBreakdown {
+	total := cidrSize(ipNet)
+	reserved := subnetEdges(family)
+	if family == IPv6 && total.Cmp(serviceIPAllocatorMax) > 0 {
+		beyond := new(big.Int).Sub(total, serviceIPAllocatorMax)
+		reserved = append(reserved, Reservation{Reason: "beyond the 65536 addresses the service IP allocator uses", Role: AddressUnusable, Count: beyond})
+	}
+	reserved = append(reserved, reserveAt(AddressService, "kubernetes API service", kubernetesServiceOffset), reserveAt(AddressService, "cluster DNS service", dnsServiceOffset))
+	return newAddressBreakdown(total, reserved)
+}
+
+// machineAddresses assumes the first usable address of the machine network
+// is its default gateway.
+func machineAddresses(family IPFamily, ipNet *net.IPNet) AddressBreakdown {
+	reserved := append(subnetEdges(family), reserveAt(AddressGateway, "default gateway", 1))
+	return newAddressBreakdown(cidrSize(ipNet), reserved)
+}
+
+// addReservations merges reservations by reason, multiplying the counts of
+// added by n, to total the per-node reservations over all node subnets. The
+// totals have no offset.
+func addReservations(reserved []Reservation, added []Reservation, n *big.Int) []Reservation {
+	for _, reservation := range added {
+		count := new(big.Int).Mul(reservation.Count, n)
+		merged := false
+		for i := range reserved {
+			if reserved[i].Reason == reservation.Reason {
+				reserved[i].Count = new(big.Int).Add(reserved[i].Count, count)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			reserved = append(reserved, Reservation{Reason: reservation.Reason, Role: reservation.Role, Count: count})
+		}
+	}
+	return reserved
+}
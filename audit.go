@@ -0,0 +1,302 @@
+package onc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+)
+
+// ClusterState is what an audit knows about a running cluster, whether it
+// was read from a must-gather or from the cluster itself.
+// Services and Pods are nil when they were not read, as in a must-gather.
+type ClusterState struct {
+	Network          *NetworkResources
+	MachineNetworks  []string
+	OpenShiftVersion string
+	Nodes            []ClusterNode
+	Services         []ClusterService
+	Pods             []ClusterPod
+}
+
+// ClusterNode is a node with its addresses and the pod subnets the network
+// plugin allocated to it.
+type ClusterNode struct {
+	Name        string   `json:"name"`
+	InternalIPs []string `json:"internal-ips"`
+	Subnets     []string `json:"subnets"`
+}
+
+// ClusterService is a service with cluster IPs; headless services have
+// none and are left out.
+type ClusterService struct {
+	Namespace  string   `json:"namespace"`
+	Name       string   `json:"name"`
+	ClusterIPs []string `json:"cluster-ips"`
+}
+
+// ClusterPod is a running or pending pod on the pod network. Host network
+// pods use the node IP and are left out.
+type ClusterPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Node      string `json:"node"`
+}
+
+// AuditReport compares the live use of a cluster with the capacity of its
+// configured networks. Nodes is the number of nodes in the cluster; the
+// calculated capacity is in NumNodes.
+type AuditReport struct {
+	Cni              string            `json:"cni"`
+	OpenShiftVersion string            `json:"openshift-version,omitempty"`
+	Nodes            int               `json:"nodes"`
+	NumNodes         NumNodes          `json:"number-of-nodes"`
+	NodeHeadroom     *big.Int          `json:"node-headroom"`
+	NodeSubnets      []NodeSubnetUsage `json:"node-subnets"`
+	UnallocatedNodes []string          `json:"unallocated-nodes,omitempty"`
+	ServiceIPs       []ServiceIPUsage  `json:"service-ips,omitempty"`
+	Pods             []NodePodUsage    `json:"pods,omitempty"`
+	PodHeadroom      *big.Int          `json:"pod-headroom,omitempty"`
+	Conflicts        []Conflict        `json:"conflicts"`
+	Warnings         []Finding         `json:"warnings"`
+}
+
+// NodeSubnetUsage counts the node subnets of one cluster network entry that
+// are allocated to nodes.
+type NodeSubnetUsage struct {
+	ClusterNetwork string   `json:"cluster-network"`
+	CIDR           string   `json:"cidr"`
+	Total          *big.Int `json:"total"`
+	Allocated      *big.Int `json:"allocated"`
+	Free           *big.Int `json:"free"`
+}
+
+// ServiceIPUsage counts the cluster IPs in use in a service network.
+type ServiceIPUsage struct {
+	ServiceNetwork string   `json:"service-network"`
+	Total          *big.Int `json:"total"`
+	Used           *big.Int `json:"used"`
+	Free           *big.Int `json:"free"`
+}
+
+// NodePodUsage compares the pods on a node with the pod addresses of its
+// node subnet.
+type NodePodUsage struct {
+	Node  string   `json:"node"`
+	Pods  int      `json:"pods"`
+	Limit *big.Int `json:"limit"`
+	Free  *big.Int `json:"free"`
+}
+
+const CodeNodeOutsideMachineNetwork FindingCode = "node-outside-machine-network"
+
+// Audit calculates the configured networks of a cluster and reports how
+// many node subnets are in use, how many nodes can still join, and node IPs
+// that fall inside the cluster, service or internal networks.
+func Audit(state *ClusterState) (*AuditReport, error) {
+	if state.Network == nil {
+		return nil, fmt.Errorf("no Network resource of %s or %s found", operatorAPIVersion, configAPIVersion)
+	}
+	if len(state.MachineNetworks) == 0 {
+		return nil, fieldError("machineNetwork", ErrInvalidValue, "", "the machine network is not recorded in the cluster; set it explicitly")
+	}
+	request := state.Network.Request(state.MachineNetworks...)
+	request.OpenShiftVersion = state.OpenShiftVersion
+	response, err := CalculateNetwork(request)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AuditReport{
+		Cni:              response.Cni,
+		OpenShiftVersion: response.OpenShiftVersion,
+		Nodes:            len(state.Nodes),
+		NumNodes:         response.NumNodes,
+		Conflicts:        []Conflict{},
+		Warnings:         response.Warnings,
+	}
+	report.NodeHeadroom = new(big.Int).Sub(response.NumNodes.Max, big.NewInt(int64(len(state.Nodes))))
+
+	var networks []NamedNetwork
+	var clusterNets []*net.IPNet
+	for i, family := range response.Families {
+		for _, clusterNetwork := range family.ClusterNetworks {
+			_, ipNet, _ := net.ParseCIDR(clusterNetwork.CIDR)
+			clusterNets = append(clusterNets, ipNet)
+			report.NodeSubnets = append(report.NodeSubnets, NodeSubnetUsage{
+				ClusterNetwork: clusterNetwork.Name,
+				CIDR:           clusterNetwork.CIDR,
+				Total:          clusterNetwork.NumNodes,
+				Allocated:      new(big.Int),
+			})
+			networks = append(networks, NamedNetwork{Name: clusterNetwork.Name, Role: RoleCluster, CIDR: clusterNetwork.CIDR})
+		}
+		networks = append(networks, NamedNetwork{Name: fmt.Sprintf("serviceNetwork[%d]", i), Role: RoleService, CIDR: family.ServiceNetwork})
+		networks = append(networks, family.InternalSubnets...)
+	}
+
+	var machineNets []*net.IPNet
+	for _, cidr := range state.MachineNetworks {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		machineNets = append(machineNets, ipNet)
+	}
+
+	for _, node := range state.Nodes {
+		if len(node.Subnets) == 0 {
+			report.UnallocatedNodes = append(report.UnallocatedNodes, node.Name)
+		}
+		for _, subnet := range node.Subnets {
+			ip, _, err := net.ParseCIDR(subnet)
+			if err != nil {
+				return nil, fmt.Errorf("node %s has an invalid subnet %q", node.Name, subnet)
+			}
+			for i, clusterNet := range clusterNets {
+				if clusterNet.Contains(ip) {
+					report.NodeSubnets[i].Allocated.Add(report.NodeSubnets[i].Allocated, big.NewInt(1))
+					break
+				}
+			}
+		}
+		for _, address := range node.InternalIPs {
+			conflicts, findings := auditNodeIP(node.Name, address, networks, machineNets, state.MachineNetworks)
+			report.Conflicts = append(report.Conflicts, conflicts...)
+			report.Warnings = append(report.Warnings, findings...)
+		}
+	}
+	for i := range report.NodeSubnets {
+		usage := &report.NodeSubnets[i]
+		usage.Free = new(big.Int).Sub(usage.Total, usage.Allocated)
+	}
+	if state.Services != nil {
+		report.ServiceIPs = auditServiceIPs(response, state.Services)
+	}
+	if state.Pods != nil {
+		report.Pods, report.PodHeadroom = auditPods(response, state.Nodes, state.Pods, report.NodeHeadroom)
+	}
+	return report, nil
+}
+
+// auditServiceIPs counts the cluster IPs of the services in each service
+// network. The kubernetes and DNS service IPs are already reserved in the
+// total, so their services are not counted again.
+func auditServiceIPs(response *Response, services []ClusterService) []ServiceIPUsage {
+	var usages []ServiceIPUsage
+	for _, family := range response.Families {
+		_, ipNet, _ := net.ParseCIDR(family.ServiceNetwork)
+		reserved := make(map[string]bool)
+		for _, serviceIP := range family.ServiceIPs {
+			reserved[serviceIP.IP] = true
+		}
+		used := new(big.Int)
+		for _, service := range services {
+			for _, clusterIP := range service.ClusterIPs {
+				ip := net.ParseIP(clusterIP)
+				if ip != nil && ipNet.Contains(ip) && !reserved[ip.String()] {
+					used.Add(used, big.NewInt(1))
+				}
+			}
+		}
+		total := family.Addresses.Service.Allocatable
+		usages = append(usages, ServiceIPUsage{
+			ServiceNetwork: family.ServiceNetwork,
+			Total:          total,
+			Used:           used,
+			Free:           new(big.Int).Sub(total, used),
+		})
+	}
+	return usages
+}
+
+// auditPods compares the pods on every node with the pods-per-node limit of
+// the node subnets. The pod headroom is the free pod addresses on the
+// existing nodes plus those of the nodes that can still join.
+func auditPods(response *Response, nodes []ClusterNode, pods []ClusterPod, nodeHeadroom *big.Int) ([]NodePodUsage, *big.Int) {
+	perNode := make(map[string]int)
+	for _, pod := range pods {
+		perNode[pod.Node]++
+	}
+	limit := response.PodsPerNode
+	headroom := new(big.Int)
+	var usages []NodePodUsage
+	for _, node := range nodes {
+		free := new(big.Int).Sub(limit, big.NewInt(int64(perNode[node.Name])))
+		usages = append(usages, NodePodUsage{Node: node.Name, Pods: perNode[node.Name], Limit: limit, Free: free})
+		if free.Sign() > 0 {
+			headroom.Add(headroom, free)
+		}
+	}
+	if nodeHeadroom.Sign() > 0 {
+		headroom.Add(headroom, new(big.Int).Mul(nodeHeadroom, limit))
+	}
+	return usages, headroom
+}
+
+// auditNodeIP reports a node IP inside any of the networks as a conflict,
+// and one outside every machine network as a warning.
+func auditNodeIP(name, address string, networks []NamedNetwork, machineNets []*net.IPNet, machineNetworks []string) ([]Conflict, []Finding) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, nil
+	}
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
+	host := &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	node := NamedNetwork{Name: "node/" + name, Role: RoleMachine, CIDR: host.String()}
+
+	var conflicts []Conflict
+	for _, network := range networks {
+		_, ipNet, _ := net.ParseCIDR(network.CIDR)
+		if ipNet.Contains(ip) {
+			conflicts = append(conflicts, Conflict{
+				NetworkA: node,
+				NetworkB: network,
+				Overlap:  host.String(),
+				Severity: SeverityError,
+				Message:  conflictMessage(node, network, host),
+			})
+		}
+	}
+	for _, machineNet := range machineNets {
+		if machineNet.Contains(ip) {
+			return conflicts, nil
+		}
+	}
+	return conflicts, []Finding{{
+		Code:        CodeNodeOutsideMachineNetwork,
+		Severity:    SeverityWarning,
+		Networks:    []NamedNetwork{node},
+		Message:     fmt.Sprintf("Node %s has the IP %s outside the machine network %v.", name, address, machineNetworks),
+		Remediation: "Add the node network to networking.machineNetwork so that the calculation and the host network checks cover it.",
+	}}
+}
+
+// parseOVNNodeSubnets reads the k8s.ovn.org/node-subnets annotation, which
+// maps network names to one subnet or, since dual-stack, a list of them.
+func parseOVNNodeSubnets(annotation string) ([]string, error) {
+	var networks map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(annotation), &networks); err != nil {
+		return nil, err
+	}
+	raw, ok := networks["default"]
+	if !ok {
+		return nil, nil
+	}
+	var subnets []string
+	if err := json.Unmarshal(raw, &subnets); err == nil {
+		return subnets, nil
+	}
+	var subnet string
+	if err := json.Unmarshal(raw, &subnet); err != nil {
+		return nil, err
+	}
+	return []string{subnet}, nil
+}
+
+func sortNodes(nodes []ClusterNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+}
@@ -0,0 +1,92 @@
+package onc
+
+// CalicoConfig mirrors the IP pool settings of the Calico installation.
+type CalicoConfig struct {
+	// IPv4BlockSize and IPv6BlockSize are the prefix lengths of the IPAM
+	// blocks of each IP pool; they default to 26 and 122.
+	IPv4BlockSize int `json:"ipv4BlockSize,omitempty"`
+	IPv6BlockSize int `json:"ipv6BlockSize,omitempty"`
+	// Encapsulation is IPIP, VXLAN (the default) or None.
+	Encapsulation string `json:"encapsulation,omitempty"`
+}
+
+// calico hands out IPAM blocks instead of one subnet per node. A node
+// claims further blocks as it fills up, so the node count is the number of
+// blocks and pods-per-node is bounded by the kubelet, not by a block.
+type calico struct{}
+
+var calicoBlockSizes = map[IPFamily]struct {
+	field         string
+	min, max, def int
+}{
+	IPv4: {field: "calicoConfig.ipv4BlockSize", min: 20, max: 32, def: 26},
+	IPv6: {field: "calicoConfig.ipv6BlockSize", min: 116, max: 128, def: 122},
+}
+
+func init() {
+	RegisterCNI(calico{})
+}
+
+func (calico) Name() string {
+	return "calico"
+}
+
+func (calico) SupportedFamilies() []IPFamily {
+	return []IPFamily{IPv4, IPv6}
+}
+
+func (calico) Defaults() CNIDefaults {
+	return openshiftDefaults
+}
+
+func (calico) Supported(release Release) error {
+	return nil
+}
+
+func (calico) NodePrefix(family IPFamily, hostPrefix int, request Request) (int, error) {
+	sizes := calicoBlockSizes[family]
+	blockSize := calicoBlockSize(family, request)
+	if blockSize == 0 {
+		return sizes.def, nil
+	}
+	if blockSize < sizes.min || blockSize > sizes.max {
+		return 0, fieldError(sizes.field, ErrOutOfRange, blockSize, "%s %d is out of range; %s blocks must be /%d to /%d", sizes.field, blockSize, family, sizes.min, sizes.max)
+	}
+	return blockSize, nil
+}
+
+func (calico) AllocatesBlocks() bool {
+	return true
+}
+
+func (calico) NodePrefixField(family IPFamily, request Request) string {
+	if calicoBlockSize(family, request) == 0 {
+		return ""
+	}
+	return calicoBlockSizes[family].field
+}
+
+// calicoBlockSize returns the configured block size of a family, or 0.
+func calicoBlockSize(family IPFamily, request Request) int {
+	if request.CalicoConfig == nil {
+		return 0
+	}
+	if family == IPv6 {
+		return request.CalicoConfig.IPv6BlockSize
+	}
+	return request.CalicoConfig.IPv4BlockSize
+}
+
+// NodeReservations counts the tunnel address Calico takes from the first
+// block of every node when the pool is encapsulated. Blocks have no network
+// or broadcast address.
+func (calico) NodeReservations(family IPFamily, request Request) []Reservation {
+	if request.CalicoConfig != nil && request.CalicoConfig.Encapsulation == "None" {
+		return nil
+	}
+	return []Reservation{reserve(AddressTunnel, "Calico tunnel address")}
+}
+
+func (calico) InternalSubnets(family IPFamily, request Request, release Release) ([]NamedNetwork, error) {
+	return nil, nil
+}
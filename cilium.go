@@ -0,0 +1,75 @@
+package onc
+
+// CiliumConfig mirrors the cluster-pool IPAM settings of the Cilium
+// installation.
+type CiliumConfig struct {
+	// ClusterPoolIPv4MaskSize and ClusterPoolIPv6MaskSize are the per-node
+	// pod CIDR sizes; they default to the cluster network hostPrefix.
+	ClusterPoolIPv4MaskSize int `json:"clusterPoolIPv4MaskSize,omitempty"`
+	ClusterPoolIPv6MaskSize int `json:"clusterPoolIPv6MaskSize,omitempty"`
+	// IngressController enables Cilium ingress, which takes another
+	// address from every node pod CIDR.
+	IngressController bool `json:"ingressController,omitempty"`
+}
+
+type cilium struct{}
+
+func init() {
+	RegisterCNI(cilium{})
+}
+
+func (cilium) Name() string {
+	return "cilium"
+}
+
+func (cilium) SupportedFamilies() []IPFamily {
+	return []IPFamily{IPv4, IPv6}
+}
+
+func (cilium) Defaults() CNIDefaults {
+	return openshiftDefaults
+}
+
+func (cilium) Supported(release Release) error {
+	return nil
+}
+
+func (cilium) NodePrefix(family IPFamily, hostPrefix int, request Request) (int, error) {
+	if maskSize := ciliumMaskSize(family, request); maskSize != 0 {
+		return maskSize, nil
+	}
+	return hostPrefix, nil
+}
+
+func (cilium) NodePrefixField(family IPFamily, request Request) string {
+	if ciliumMaskSize(family, request) == 0 {
+		return ""
+	}
+	return "ciliumConfig.clusterPool" + string(family) + "MaskSize"
+}
+
+// ciliumMaskSize returns the configured mask size of a family, or 0.
+func ciliumMaskSize(family IPFamily, request Request) int {
+	if request.CiliumConfig == nil {
+		return 0
+	}
+	if family == IPv6 {
+		return request.CiliumConfig.ClusterPoolIPv6MaskSize
+	}
+	return request.CiliumConfig.ClusterPoolIPv4MaskSize
+}
+
+// NodeReservations adds the cilium_host router address and the health
+// endpoint, plus the ingress address when Cilium ingress is enabled, to the
+// subnet edges.
+func (cilium) NodeReservations(family IPFamily, request Request) []Reservation {
+	reserved := append(subnetEdges(family), reserve(AddressRouter, "cilium_host router"), reserve(AddressHealth, "Cilium health endpoint"))
+	if request.CiliumConfig != nil && request.CiliumConfig.IngressController {
+		reserved = append(reserved, reserve(AddressIngress, "Cilium ingress"))
+	}
+	return reserved
+}
+
+func (cilium) InternalSubnets(family IPFamily, request Request, release Release) ([]NamedNetwork, error) {
+	return nil, nil
+}
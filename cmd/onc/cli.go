@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kevydotvinu/onc"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"calculate", "calculate capacity and conflicts for a JSON request", runCalculate},
+	{"render", "render the install-config networking stanza for a JSON request", runRender},
+	{"audit", "audit the network usage of a cluster or a must-gather directory", runAudit},
+}
+
+// runCLI runs a subcommand and returns the process exit code.
+func runCLI(args []string) int {
+	for _, c := range commands {
+		if c.name == args[0] {
+			if err := c.run(args[1:]); err != nil {
+				printError(c.name, err)
+				return 1
+			}
+			return 0
+		}
+	}
+	fmt.Fprintf(os.Stderr, "onc: unknown command %q\n\nCommands:\n", args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.summary)
+	}
+	return 2
+}
+
+// printError prints each field error of an invalid request on its own line.
+func printError(name string, err error) {
+	var validationErr *onc.ValidationError
+	switch {
+	case err == flag.ErrHelp:
+	case errors.As(err, &validationErr):
+		for _, fieldErr := range validationErr.Errors {
+			if fieldErr.Line > 0 {
+				fmt.Fprintf(os.Stderr, "onc %s: line %d: %s: %s\n", name, fieldErr.Line, fieldErr.Field, fieldErr.Message)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "onc %s: %s: %s\n", name, fieldErr.Field, fieldErr.Message)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "onc %s: %v\n", name, err)
+	}
+}
+
+// listFlag collects the values of a flag given more than once.
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+func runCalculate(args []string) error {
+	fs := flag.NewFlagSet("calculate", flag.ContinueOnError)
+	file := fs.String("f", "-", "request JSON file, or - for stdin")
+	installConfig := fs.String("install-config", "", "read the networks from an install-config.yaml instead of a JSON request")
+	networkFile := fs.String("network", "", "read the networks from Network resources exported with oc get -o yaml")
+	var machineNetworks listFlag
+	fs.Var(&machineNetworks, "machine-network", "machine network CIDR for -network; may be repeated")
+	var exclusionFiles listFlag
+	fs.Var(&exclusionFiles, "exclusions", "exclusion list file of CIDRs and names; may be repeated")
+	failOn := fs.String("fail-on", "", "comma-separated finding codes that make the command fail, or \"all\"")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var exclusions []onc.Exclusion
+	for _, path := range exclusionFiles {
+		list, err := onc.LoadExclusions(path)
+		if err != nil {
+			return err
+		}
+		exclusions = append(exclusions, list...)
+	}
+
+	var response *onc.Response
+	if *installConfig != "" {
+		data, err := os.ReadFile(*installConfig)
+		if err != nil {
+			return err
+		}
+		if response, err = onc.CalculateInstallConfig(data, exclusions...); err != nil {
+			return err
+		}
+	} else {
+		var request onc.Request
+		if *networkFile != "" {
+			data, err := os.ReadFile(*networkFile)
+			if err != nil {
+				return err
+			}
+			resources, err := onc.ParseNetworkResources(data)
+			if err != nil {
+				return fmt.Errorf("%s: %v", *networkFile, err)
+			}
+			request = resources.Request(machineNetworks...)
+		} else if err := readJSON(*file, &request); err != nil {
+			return err
+		}
+		request.Exclusions = append(request.Exclusions, exclusions...)
+		var err error
+		if response, err = onc.CalculateNetwork(request); err != nil {
+			return err
+		}
+	}
+	if err := writeJSON(os.Stdout, response); err != nil {
+		return err
+	}
+	return checkFailOn(*failOn, response.Warnings)
+}
+
+// checkFailOn returns an error naming the findings that match codes.
+func checkFailOn(codes string, findings []onc.Finding) error {
+	if codes == "" {
+		return nil
+	}
+	var failed []string
+	for _, finding := range findings {
+		for _, code := range strings.Split(codes, ",") {
+			code = strings.TrimSpace(code)
+			if code == "all" || onc.FindingCode(code) == finding.Code {
+				failed = append(failed, fmt.Sprintf("%s: %s", finding.Code, finding.Message))
+				break
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d finding(s) matched -fail-on:\n  %s", len(failed), strings.Join(failed, "\n  "))
+	}
+	return nil
+}
+
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	file := fs.String("f", "-", "request JSON file, or - for stdin")
+	manifests := fs.String("manifests", "", "write "+onc.ClusterNetworkConfigFile+" to this directory instead of stdout")
+	operator := fs.Bool("operator", false, "render the networks.operator.openshift.io/cluster resource instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var request onc.Request
+	if err := readJSON(*file, &request); err != nil {
+		return err
+	}
+	if *operator {
+		network, err := onc.RenderOperatorNetwork(request)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(network)
+		return err
+	}
+	rendered, err := onc.RenderInstallConfig(request)
+	if err != nil {
+		return err
+	}
+	if *manifests == "" || rendered.ClusterNetworkConfig == nil {
+		_, err = os.Stdout.Write(rendered.YAML())
+		return err
+	}
+	if _, err := os.Stdout.Write(rendered.Networking); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(*manifests, onc.ClusterNetworkConfigFile), rendered.ClusterNetworkConfig, 0o644)
+}
+
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	var machineNetworks listFlag
+	fs.Var(&machineNetworks, "machine-network", "machine network CIDR when the cluster lacks the install-config; may be repeated")
+	failOn := fs.String("fail-on", "", "comma-separated finding codes that make the command fail, or \"all\"")
+	kubeconfig := fs.String("kubeconfig", onc.DefaultKubeconfig(), "kubeconfig of the cluster to audit")
+	kubeContext := fs.String("context", "", "kubeconfig context; defaults to the current context")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: onc audit [flags] [must-gather-directory]")
+		fmt.Fprintln(fs.Output(), "Without a directory the cluster of the kubeconfig is audited.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	var report *onc.AuditReport
+	if fs.NArg() == 1 {
+		state, err := onc.LoadMustGather(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if len(machineNetworks) > 0 {
+			state.MachineNetworks = machineNetworks
+		}
+		if report, err = onc.Audit(state); err != nil {
+			return err
+		}
+	} else {
+		client, err := onc.LoadKubeconfig(*kubeconfig, *kubeContext)
+		if err != nil {
+			return err
+		}
+		if report, err = onc.AuditCluster(context.Background(), client, machineNetworks...); err != nil {
+			return err
+		}
+	}
+	if err := writeJSON(os.Stdout, report); err != nil {
+		return err
+	}
+	if len(report.Conflicts) > 0 {
+		return fmt.Errorf("%d node IP(s) overlap the cluster networks", len(report.Conflicts))
+	}
+	return checkFailOn(*failOn, report.Warnings)
+}
+
+func readJSON(path string, v interface{}) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("Failed to parse payload: %v", err)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/kevydotvinu/onc"
@@ -12,10 +14,6 @@ import (
 
 func calculatorHandler(request events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
 
-	type ErrorResponse struct {
-		Error string `json:"error"`
-	}
-
 	// Log request
 	fmt.Printf("Incoming Request: %+v\n", request)
 
@@ -36,29 +34,67 @@ func calculatorHandler(request events.APIGatewayProxyRequest) (*events.APIGatewa
 		}
 	}
 
-	var req onc.Request
-	if err := json.NewDecoder(strings.NewReader(request.Body)).Decode(&req); err != nil {
-		return &events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Body:       fmt.Sprintf("Failed to parse payload: %v", err),
-		}, nil
-	}
-
-	var results *onc.Response
+	var results interface{}
 	var err error
-	if results, err = onc.CalculateNetwork(req); err != nil {
-		errorResponse := ErrorResponse{
-			Error: fmt.Sprintf("failed calculation: %v", err),
+	switch path.Base(request.Path) {
+	case "node-subnets":
+		var req onc.NodeSubnetsRequest
+		if err := json.NewDecoder(strings.NewReader(request.Body)).Decode(&req); err != nil {
+			return parseFailure(err), nil
+		}
+		results, err = onc.ListNodeSubnets(req)
+	case "lookup":
+		var req onc.PodIPRequest
+		if err := json.NewDecoder(strings.NewReader(request.Body)).Decode(&req); err != nil {
+			return parseFailure(err), nil
+		}
+		results, err = onc.LookupPodIP(req)
+	case "suggest":
+		var req onc.SuggestRequest
+		if err := json.NewDecoder(strings.NewReader(request.Body)).Decode(&req); err != nil {
+			return parseFailure(err), nil
+		}
+		results, err = onc.Suggest(req)
+	case "size":
+		var req onc.SizingRequest
+		if err := json.NewDecoder(strings.NewReader(request.Body)).Decode(&req); err != nil {
+			return parseFailure(err), nil
+		}
+		results, err = onc.Size(req)
+	case "releases":
+		results = onc.SupportedReleases()
+	case "install-config":
+		config, parseErr := onc.ParseInstallConfig([]byte(request.Body))
+		if parseErr != nil {
+			return parseFailure(parseErr), nil
+		}
+		results, err = config.Calculate()
+	default:
+		var req onc.Request
+		if err := json.NewDecoder(strings.NewReader(request.Body)).Decode(&req); err != nil {
+			return parseFailure(err), nil
+		}
+		// ?format=install-config answers with the networking stanza and
+		// any manifest, ?format=operator with the operator Network
+		// resource, as YAML instead of the calculation.
+		switch request.QueryStringParameters["format"] {
+		case "install-config":
+			rendered, err := onc.RenderInstallConfig(req)
+			if err != nil {
+				return errorResponse(err), nil
+			}
+			return yamlResponse(rendered.YAML()), nil
+		case "operator":
+			network, err := onc.RenderOperatorNetwork(req)
+			if err != nil {
+				return errorResponse(err), nil
+			}
+			return yamlResponse(network), nil
 		}
-		output, _ := json.Marshal(errorResponse)
-		return &events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-			Body:            string(output),
-			IsBase64Encoded: false,
-		}, nil
+		results, err = onc.CalculateNetwork(req)
+	}
+	if err != nil {
+		return errorResponse(err), nil
 	}
 
 	output, err := json.Marshal(results)
@@ -82,3 +118,57 @@ func calculatorHandler(request events.APIGatewayProxyRequest) (*events.APIGatewa
 		IsBase64Encoded: false,
 	}, nil
 }
+
+type ErrorResponse struct {
+	Error  string            `json:"error"`
+	Errors []*onc.FieldError `json:"errors,omitempty"`
+}
+
+func parseFailure(err error) *events.APIGatewayProxyResponse {
+	return jsonError(400, ErrorResponse{
+		Error: fmt.Sprintf("Failed to parse payload: %v", err),
+	})
+}
+
+// errorResponse answers invalid requests with 422 and the field errors, and
+// anything else with 500.
+func errorResponse(err error) *events.APIGatewayProxyResponse {
+	var validationErr *onc.ValidationError
+	var fieldErr *onc.FieldError
+	switch {
+	case errors.As(err, &validationErr):
+		return jsonError(422, ErrorResponse{Error: validationErr.Error(), Errors: validationErr.Errors})
+	case errors.As(err, &fieldErr):
+		return jsonError(422, ErrorResponse{Error: fieldErr.Error(), Errors: []*onc.FieldError{fieldErr}})
+	}
+	return jsonError(500, ErrorResponse{
+		Error: fmt.Sprintf("failed calculation: %v", err),
+	})
+}
+
+func yamlResponse(body []byte) *events.APIGatewayProxyResponse {
+	return &events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Content-Type":                 "application/yaml",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Headers": "*",
+		},
+		Body:            string(body),
+		IsBase64Encoded: false,
+	}
+}
+
+func jsonError(statusCode int, errorResponse ErrorResponse) *events.APIGatewayProxyResponse {
+	output, _ := json.Marshal(errorResponse)
+	return &events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers: map[string]string{
+			"Content-Type":                 "application/json",
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Headers": "*",
+		},
+		Body:            string(output),
+		IsBase64Encoded: false,
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/aws/aws-lambda-go/lambda"
 )
@@ -9,6 +10,11 @@ import (
 var version string
 
 func main() {
+	// With arguments onc runs as a command line tool, otherwise as the
+	// Netlify function behind the site.
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
 	fmt.Printf("Starting onc, version %s\n", version)
 	lambda.Start(calculatorHandler)
 }
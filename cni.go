@@ -0,0 +1,138 @@
+package onc
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultCNI is used when a request does not name a CNI, as in
+// install-config.
+const DefaultCNI = "ovn-kubernetes"
+
+// CNI describes how a network plugin uses the cluster networks. Profiles
+// are registered by name with RegisterCNI and selected by Request.Cni.
+type CNI interface {
+	Name() string
+	// SupportedFamilies lists the IP families the plugin can run.
+	SupportedFamilies() []IPFamily
+	// Defaults are the networks used when a request leaves them empty.
+	Defaults() CNIDefaults
+	// Supported returns an error when the plugin cannot be installed on
+	// the release.
+	Supported(release Release) error
+	// NodePrefix is the prefix length of the pod subnets the plugin hands
+	// out, usually the cluster network hostPrefix.
+	NodePrefix(family IPFamily, hostPrefix int, request Request) (int, error)
+	// NodeReservations lists the addresses of every node subnet that pods
+	// cannot use (network address, gateway, management port and so on).
+	NodeReservations(family IPFamily, request Request) []Reservation
+	// InternalSubnets returns the subnets the plugin uses internally, which
+	// must not overlap the cluster networks.
+	InternalSubnets(family IPFamily, request Request, release Release) ([]NamedNetwork, error)
+}
+
+// NodePrefixConfig is implemented by CNIs whose node subnet size can be set
+// in their own configuration rather than by the hostPrefix.
+type NodePrefixConfig interface {
+	// NodePrefixField names the request field that sets the node prefix of
+	// the family, or is empty when the CNI uses its default.
+	NodePrefixField(family IPFamily, request Request) string
+}
+
+// DefaultNetworkConfigurer is implemented by CNIs whose internal subnets
+// are set in the defaultNetwork of the Cluster Network Operator
+// configuration.
+type DefaultNetworkConfigurer interface {
+	// ConfigureDefaultNetwork sets the subnets, named by their request
+	// field, in network.
+	ConfigureDefaultNetwork(network *DefaultNetwork, subnets []NamedNetwork)
+}
+
+// BlockAllocator is implemented by CNIs that hand out IPAM blocks on demand
+// instead of one subnet per node. A node claims more blocks as it fills up,
+// so the capacity of one block does not limit the pods of a node.
+type BlockAllocator interface {
+	AllocatesBlocks() bool
+}
+
+func allocatesBlocks(cni CNI) bool {
+	allocator, ok := cni.(BlockAllocator)
+	return ok && allocator.AllocatesBlocks()
+}
+
+// NamespaceAllocator is implemented by CNIs that give every namespace,
+// rather than every node, a subnet of the cluster network. The cluster
+// network then limits namespaces and not nodes.
+type NamespaceAllocator interface {
+	AllocatesPerNamespace() bool
+}
+
+func allocatesPerNamespace(cni CNI) bool {
+	allocator, ok := cni.(NamespaceAllocator)
+	return ok && allocator.AllocatesPerNamespace()
+}
+
+type CNIDefaults struct {
+	ClusterNetwork string
+	HostPrefix     int
+	ServiceNetwork string
+}
+
+// openshiftDefaults are the installer defaults, which every CNI profile
+// uses unless it needs other networks.
+var openshiftDefaults = CNIDefaults{ClusterNetwork: "10.128.0.0/14", HostPrefix: 23, ServiceNetwork: "172.30.0.0/16"}
+
+var cnis = make(map[string]CNI)
+
+func RegisterCNI(cni CNI) {
+	cnis[cni.Name()] = cni
+}
+
+// LookupCNI returns the profile registered under name, or the default CNI
+// profile when name is empty.
+func LookupCNI(name string) (CNI, error) {
+	if name == "" {
+		name = DefaultCNI
+	}
+	cni, ok := cnis[name]
+	if !ok {
+		return nil, fieldError("cni", ErrUnknown, name, "Unknown CNI: %q; supported CNIs are %s", name, strings.Join(CNINames(), ", "))
+	}
+	return cni, nil
+}
+
+func CNINames() []string {
+	var names []string
+	for name := range cnis {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkFamily returns a field error on cni when it does not support the
+// family.
+func checkFamily(cni CNI, family IPFamily) *FieldError {
+	for _, supported := range cni.SupportedFamilies() {
+		if supported == family {
+			return nil
+		}
+	}
+	return fieldError("cni", ErrUnsupported, cni.Name(), "%s does not support %s networks", cni.Name(), family)
+}
+
+// applyDefaults fills the networks a single-stack request left empty from
+// the CNI defaults.
+func applyDefaults(request Request, cni CNI) Request {
+	defaults := cni.Defaults()
+	if request.ClusterNetwork == "" && len(request.ClusterNetworks) == 0 {
+		request.ClusterNetwork = defaults.ClusterNetwork
+	}
+	if request.HostPrefix == 0 && len(request.ClusterNetworks) == 0 {
+		request.HostPrefix = defaults.HostPrefix
+	}
+	if request.ServiceNetwork == "" && len(request.ServiceNetworks) == 0 {
+		request.ServiceNetwork = defaults.ServiceNetwork
+	}
+	return request
+}
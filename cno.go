@@ -0,0 +1,192 @@
+package onc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	operatorAPIVersion = "operator.openshift.io/v1"
+	configAPIVersion   = "config.openshift.io/v1"
+)
+
+type ObjectMeta struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+// OperatorNetwork is the networks.operator.openshift.io/cluster resource
+// of the Cluster Network Operator, limited to the fields the calculator
+// understands.
+type OperatorNetwork struct {
+	APIVersion string              `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string              `json:"kind" yaml:"kind"`
+	Metadata   ObjectMeta          `json:"metadata" yaml:"metadata"`
+	Spec       OperatorNetworkSpec `json:"spec" yaml:"spec"`
+}
+
+type OperatorNetworkSpec struct {
+	ClusterNetwork []ClusterNetworkEntry `json:"clusterNetwork,omitempty" yaml:"clusterNetwork,omitempty"`
+	ServiceNetwork []string              `json:"serviceNetwork,omitempty" yaml:"serviceNetwork,omitempty"`
+	DefaultNetwork DefaultNetwork        `json:"defaultNetwork" yaml:"defaultNetwork"`
+}
+
+type DefaultNetwork struct {
+	Type                string               `json:"type,omitempty" yaml:"type,omitempty"`
+	OVNKubernetesConfig *OVNKubernetesConfig `json:"ovnKubernetesConfig,omitempty" yaml:"ovnKubernetesConfig,omitempty"`
+}
+
+// ConfigNetwork is the networks.config.openshift.io/cluster resource. Its
+// status holds the networks in use once the operator has applied them.
+type ConfigNetwork struct {
+	APIVersion string            `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string            `json:"kind" yaml:"kind"`
+	Metadata   ObjectMeta        `json:"metadata" yaml:"metadata"`
+	Spec       ConfigNetworkSpec `json:"spec" yaml:"spec"`
+	Status     ConfigNetworkSpec `json:"status" yaml:"status"`
+}
+
+type ConfigNetworkSpec struct {
+	ClusterNetwork []ClusterNetworkEntry `json:"clusterNetwork,omitempty" yaml:"clusterNetwork,omitempty"`
+	ServiceNetwork []string              `json:"serviceNetwork,omitempty" yaml:"serviceNetwork,omitempty"`
+	NetworkType    string                `json:"networkType,omitempty" yaml:"networkType,omitempty"`
+}
+
+// NetworkResources are the Network resources found in a document.
+type NetworkResources struct {
+	Operator *OperatorNetwork
+	Config   *ConfigNetwork
+}
+
+// ParseNetworkResources reads the Network resources from YAML or JSON as
+// exported by "oc get -o yaml": single resources, several documents or a
+// List. Other resources are ignored.
+func ParseNetworkResources(data []byte) (*NetworkResources, error) {
+	resources := &NetworkResources{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if err := resources.add(&node); err != nil {
+			return nil, err
+		}
+	}
+	if resources.Operator == nil && resources.Config == nil {
+		return nil, fmt.Errorf("no Network resource of %s or %s found", operatorAPIVersion, configAPIVersion)
+	}
+	return resources, nil
+}
+
+func (r *NetworkResources) add(node *yaml.Node) error {
+	var header struct {
+		APIVersion string      `yaml:"apiVersion"`
+		Kind       string      `yaml:"kind"`
+		Items      []yaml.Node `yaml:"items"`
+	}
+	if err := node.Decode(&header); err != nil {
+		return err
+	}
+	switch {
+	case header.Kind == "List" || header.Kind == "NetworkList":
+		for i := range header.Items {
+			if err := r.add(&header.Items[i]); err != nil {
+				return err
+			}
+		}
+	case header.Kind == "Network":
+		return r.addAs(node, header.APIVersion)
+	}
+	return nil
+}
+
+// addAs decodes a Network resource of the given API version, which API
+// server list items leave out.
+func (r *NetworkResources) addAs(node *yaml.Node, apiVersion string) error {
+	switch apiVersion {
+	case operatorAPIVersion:
+		r.Operator = &OperatorNetwork{}
+		return node.Decode(r.Operator)
+	case configAPIVersion:
+		r.Config = &ConfigNetwork{}
+		return node.Decode(r.Config)
+	}
+	return nil
+}
+
+// Request converts the resources into a calculator request for the given
+// machine network, which neither resource records. The config status is
+// preferred since it holds what the cluster uses; the operator resource
+// provides the OVN-Kubernetes internal subnets.
+func (r *NetworkResources) Request(machineNetworks ...string) Request {
+	var request Request
+	if r.Operator != nil {
+		request.ClusterNetworks = r.Operator.Spec.ClusterNetwork
+		request.ServiceNetworks = r.Operator.Spec.ServiceNetwork
+		request.Cni = r.Operator.Spec.DefaultNetwork.Type
+		request.OVNKubernetesConfig = r.Operator.Spec.DefaultNetwork.OVNKubernetesConfig
+	}
+	if r.Config != nil {
+		for _, spec := range []ConfigNetworkSpec{r.Config.Spec, r.Config.Status} {
+			if len(spec.ClusterNetwork) > 0 {
+				request.ClusterNetworks = spec.ClusterNetwork
+			}
+			if len(spec.ServiceNetwork) > 0 {
+				request.ServiceNetworks = spec.ServiceNetwork
+			}
+			if spec.NetworkType != "" {
+				request.Cni = spec.NetworkType
+			}
+		}
+	}
+	if cni, ok := networkTypes[request.Cni]; ok {
+		request.Cni = cni
+	}
+	request.MachineNetworks = machineNetworks
+	return request
+}
+
+// RenderOperatorNetwork calculates a request and renders the operator
+// Network resource for it. Internal subnets are set only where they differ
+// from the release defaults: a cluster upgraded from an older release keeps
+// the defaults it was installed with, which pinning the newer ones would
+// change.
+func RenderOperatorNetwork(request Request) ([]byte, error) {
+	response, err := CalculateNetwork(request)
+	if err != nil {
+		return nil, err
+	}
+	release, _ := LookupRelease(request.OpenShiftVersion)
+	cni, _ := LookupCNI(request.Cni)
+	request = applyDefaults(request, cni)
+
+	network := newOperatorNetwork()
+	network.Spec.ClusterNetwork = request.clusterNetworks()
+	network.Spec.ServiceNetwork = request.serviceNetworks()
+	network.Spec.DefaultNetwork.Type = networkType(cni)
+	if configurer, ok := cni.(DefaultNetworkConfigurer); ok {
+		custom, err := customInternalSubnets(response, cni, release)
+		if err != nil {
+			return nil, err
+		}
+		if len(custom) > 0 {
+			configurer.ConfigureDefaultNetwork(&network.Spec.DefaultNetwork, custom)
+		}
+	}
+	return marshalYAML(network)
+}
+
+func newOperatorNetwork() OperatorNetwork {
+	return OperatorNetwork{
+		APIVersion: operatorAPIVersion,
+		Kind:       "Network",
+		Metadata:   ObjectMeta{Name: "cluster"},
+	}
+}
@@ -0,0 +1,120 @@
+package onc
+
+import (
+	"fmt"
+	"net"
+)
+
+type NetworkRole string
+
+const (
+	RoleCluster    NetworkRole = "cluster"
+	RoleService    NetworkRole = "service"
+	RoleMachine    NetworkRole = "machine"
+	RoleJoin       NetworkRole = "join"
+	RoleTransit    NetworkRole = "transit"
+	RoleMasquerade NetworkRole = "masquerade"
+	RoleExclusion  NetworkRole = "exclusion"
+	RoleKnownRange NetworkRole = "known-range"
+)
+
+// roleUsage describes what a network's addresses are used for, to explain
+// the impact of an overlap.
+var roleUsage = map[NetworkRole]string{
+	RoleCluster:    "pod IPs",
+	RoleService:    "service cluster IPs",
+	RoleMachine:    "node IPs",
+	RoleJoin:       "the OVN join switch between node gateway routers",
+	RoleTransit:    "the OVN transit switch between zones",
+	RoleMasquerade: "OVN host-to-service masquerading",
+	RoleExclusion:  "an external network the cluster must reach",
+	RoleKnownRange: "a range other software uses by default",
+}
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+type NamedNetwork struct {
+	Name string      `json:"name"`
+	Role NetworkRole `json:"role"`
+	CIDR string      `json:"cidr"`
+}
+
+type Conflict struct {
+	NetworkA NamedNetwork `json:"network-a"`
+	NetworkB NamedNetwork `json:"network-b"`
+	Overlap  string       `json:"overlap"`
+	Severity Severity     `json:"severity"`
+	Message  string       `json:"message"`
+}
+
+func checkCIDRConflict(networks ...NamedNetwork) ([]Conflict, error) {
+	var ipNets []*net.IPNet
+
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+
+	conflicts := []Conflict{}
+	for i := range ipNets {
+		for j := i + 1; j < len(ipNets); j++ {
+			if !conflictingRoles(networks[i].Role, networks[j].Role) {
+				continue
+			}
+			overlap := overlapCIDR(ipNets[i], ipNets[j])
+			if overlap == nil {
+				continue
+			}
+			conflicts = append(conflicts, Conflict{
+				NetworkA: networks[i],
+				NetworkB: networks[j],
+				Overlap:  overlap.String(),
+				Severity: SeverityError,
+				Message:  conflictMessage(networks[i], networks[j], overlap),
+			})
+		}
+	}
+
+	return conflicts, nil
+}
+
+// conflictingRoles reports whether an overlap between two roles matters.
+// Exclusions may overlap each other and usually contain the machine
+// network, which is part of the same datacenter.
+func conflictingRoles(a, b NetworkRole) bool {
+	if a != RoleExclusion && b != RoleExclusion {
+		return true
+	}
+	return a != b && a != RoleMachine && b != RoleMachine
+}
+
+// overlapCIDR returns the range shared by two networks. CIDR blocks either
+// nest or are disjoint, so the overlap is the more specific of the two.
+func overlapCIDR(a, b *net.IPNet) *net.IPNet {
+	if !a.Contains(b.IP) && !b.Contains(a.IP) {
+		return nil
+	}
+	aOnes, _ := a.Mask.Size()
+	bOnes, _ := b.Mask.Size()
+	if aOnes >= bOnes {
+		return a
+	}
+	return b
+}
+
+func conflictMessage(a, b NamedNetwork, overlap *net.IPNet) string {
+	if a.Role == b.Role {
+		return fmt.Sprintf("%s %s and %s %s overlap in %s; the same addresses would be assigned twice for %s.",
+			a.Name, a.CIDR, b.Name, b.CIDR, overlap, roleUsage[a.Role])
+	}
+	return fmt.Sprintf("%s %s and %s %s overlap in %s; addresses used for %s would also be used for %s, so traffic to them is misrouted.",
+		a.Name, a.CIDR, b.Name, b.CIDR, overlap, roleUsage[a.Role], roleUsage[b.Role])
+}
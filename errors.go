@@ -0,0 +1,101 @@
+package onc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCode classifies a validation error. Codes are stable so that clients
+// can match on them.
+type ErrorCode string
+
+const (
+	ErrInvalidCIDR    ErrorCode = "invalid-cidr"
+	ErrInvalidValue   ErrorCode = "invalid-value"
+	ErrOutOfRange     ErrorCode = "out-of-range"
+	ErrUnknown        ErrorCode = "unknown"
+	ErrUnsupported    ErrorCode = "unsupported"
+	ErrFamilyMismatch ErrorCode = "family-mismatch"
+	ErrTooSmall       ErrorCode = "too-small"
+)
+
+// FieldError is a problem with one request field. Field is a path such as
+// clusterNetwork[0].cidr, in the install-config naming used for conflicts.
+// Line is set when the request was read from a YAML document.
+type FieldError struct {
+	Field   string    `json:"field"`
+	Code    ErrorCode `json:"code"`
+	Value   string    `json:"value,omitempty"`
+	Message string    `json:"message"`
+	Line    int       `json:"line,omitempty"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+func fieldError(field string, code ErrorCode, value interface{}, format string, args ...interface{}) *FieldError {
+	return &FieldError{
+		Field:   field,
+		Code:    code,
+		Value:   fmt.Sprint(value),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func invalidCIDR(field, cidr string) *FieldError {
+	return fieldError(field, ErrInvalidCIDR, cidr, "Invalid network CIDR: %s", cidr)
+}
+
+// ValidationError aggregates the field errors of a request. errors.As
+// finds both the ValidationError and each FieldError in it.
+type ValidationError struct {
+	Errors []*FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	var messages []string
+	for _, err := range e.Errors {
+		messages = append(messages, err.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// validation collects field errors so that every problem is reported at
+// once.
+type validation struct {
+	errs []*FieldError
+}
+
+// add records err if it is made of field errors and returns any other
+// error unchanged.
+func (v *validation) add(err error) error {
+	var validationErr *ValidationError
+	var fieldErr *FieldError
+	switch {
+	case err == nil:
+	case errors.As(err, &validationErr):
+		v.errs = append(v.errs, validationErr.Errors...)
+	case errors.As(err, &fieldErr):
+		v.errs = append(v.errs, fieldErr)
+	default:
+		return err
+	}
+	return nil
+}
+
+func (v *validation) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
@@ -0,0 +1,82 @@
+package onc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exclusion is an external network, such as a corporate range or VPN pool,
+// that the cluster must be able to reach and so must not reuse.
+type Exclusion struct {
+	Name string `json:"name"`
+	CIDR string `json:"cidr"`
+}
+
+// exclusionNetworks returns the exclusions of one family as conflict check
+// inputs, named after the exclusion or its position in the request.
+func exclusionNetworks(family IPFamily, exclusions []Exclusion) []NamedNetwork {
+	var networks []NamedNetwork
+	for i, exclusion := range exclusions {
+		if f, _ := cidrFamily(exclusion.CIDR); f != family {
+			continue
+		}
+		name := exclusion.Name
+		if name == "" {
+			name = fmt.Sprintf("exclusions[%d]", i)
+		}
+		networks = append(networks, NamedNetwork{Name: name, Role: RoleExclusion, CIDR: exclusion.CIDR})
+	}
+	return networks
+}
+
+func validateExclusions(exclusions []Exclusion) error {
+	var v validation
+	for i, exclusion := range exclusions {
+		if !isValidCIDR(exclusion.CIDR) {
+			v.errs = append(v.errs, invalidCIDR(fmt.Sprintf("exclusions[%d].cidr", i), exclusion.CIDR))
+		}
+	}
+	return v.err()
+}
+
+// ReadExclusions parses an exclusion list with one CIDR per line, optionally
+// followed by a name. Blank lines and text after '#' are ignored:
+//
+//	10.0.0.0/8      corporate
+//	192.168.64.0/20 vpn pool  # remote access
+func ReadExclusions(r io.Reader) ([]Exclusion, error) {
+	var exclusions []Exclusion
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if !isValidCIDR(fields[0]) {
+			return nil, fmt.Errorf("line %d: Invalid network CIDR: %s", line, fields[0])
+		}
+		exclusions = append(exclusions, Exclusion{Name: strings.Join(fields[1:], " "), CIDR: fields[0]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return exclusions, nil
+}
+
+// LoadExclusions reads an exclusion list file; see ReadExclusions.
+func LoadExclusions(path string) ([]Exclusion, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	exclusions, err := ReadExclusions(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return exclusions, nil
+}
@@ -0,0 +1,117 @@
+package onc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+type IPFamily string
+
+const (
+	IPv4 IPFamily = "IPv4"
+	IPv6 IPFamily = "IPv6"
+)
+
+func cidrFamily(cidr string) (IPFamily, error) {
+	ip, _, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	if ip.To4() != nil {
+		return IPv4, nil
+	}
+	return IPv6, nil
+}
+
+func (r Request) clusterNetworks() []ClusterNetworkEntry {
+	if len(r.ClusterNetworks) > 0 {
+		return r.ClusterNetworks
+	}
+	return []ClusterNetworkEntry{{CIDR: r.ClusterNetwork, HostPrefix: r.HostPrefix}}
+}
+
+func (r Request) serviceNetworks() []string {
+	if len(r.ServiceNetworks) > 0 {
+		return r.ServiceNetworks
+	}
+	return []string{r.ServiceNetwork}
+}
+
+func (r Request) machineNetworks() []string {
+	if len(r.MachineNetworks) > 0 {
+		return r.MachineNetworks
+	}
+	return []string{r.MachineNetwork}
+}
+
+// groupClusterNetworks splits the indices of the cluster network entries by
+// family, in order of first appearance. Unlike the service and machine
+// networks, a family may have several cluster network entries.
+func groupClusterNetworks(clusterNetworks []ClusterNetworkEntry) ([]IPFamily, map[IPFamily][]int, error) {
+	var families []IPFamily
+	grouped := make(map[IPFamily][]int)
+	for i, clusterNetwork := range clusterNetworks {
+		family, err := cidrFamily(clusterNetwork.CIDR)
+		if err != nil {
+			return nil, nil, invalidCIDR(fmt.Sprintf("clusterNetwork[%d].cidr", i), clusterNetwork.CIDR)
+		}
+		if _, ok := grouped[family]; !ok {
+			families = append(families, family)
+		}
+		grouped[family] = append(grouped[family], i)
+	}
+	return families, grouped, nil
+}
+
+// validateDualStack checks that the service and machine networks hold at
+// most one CIDR per family and agree with the cluster networks on the
+// families and their order, as OpenShift requires.
+func validateDualStack(families []IPFamily, serviceNetworks, machineNetworks []string) error {
+	var v validation
+	for _, network := range []struct {
+		name  string
+		cidrs []string
+	}{
+		{"serviceNetwork", serviceNetworks},
+		{"machineNetwork", machineNetworks},
+	} {
+		name := network.name
+		got, err := networkFamilies(name, network.cidrs)
+		if err != nil {
+			if err := v.add(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(got) != len(families) {
+			v.errs = append(v.errs, fieldError(name, ErrFamilyMismatch, strings.Join(network.cidrs, ","), "%s has %d families but clusterNetwork has %d; dual-stack needs one of each family in every network", name, len(got), len(families)))
+			continue
+		}
+		for i := range got {
+			if got[i] != families[i] {
+				v.errs = append(v.errs, fieldError(name, ErrFamilyMismatch, strings.Join(network.cidrs, ","), "%s family order %v does not match clusterNetwork family order %v", name, got, families))
+				break
+			}
+		}
+	}
+	return v.err()
+}
+
+func networkFamilies(name string, cidrs []string) ([]IPFamily, error) {
+	if len(cidrs) > 2 {
+		return nil, fieldError(name, ErrOutOfRange, strings.Join(cidrs, ","), "%s has %d entries; at most one IPv4 and one IPv6 network are allowed", name, len(cidrs))
+	}
+	var families []IPFamily
+	for i, cidr := range cidrs {
+		family, err := cidrFamily(cidr)
+		if err != nil {
+			return nil, invalidCIDR(fmt.Sprintf("%s[%d]", name, i), cidr)
+		}
+		families = append(families, family)
+	}
+	if len(families) == 2 && families[0] == families[1] {
+		return nil, fieldError(name, ErrFamilyMismatch, strings.Join(cidrs, ","), "%s has two %s entries; dual-stack needs one IPv4 and one IPv6 network", name, families[0])
+	}
+	return families, nil
+}
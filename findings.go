@@ -0,0 +1,115 @@
+package onc
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// FindingCode identifies a kind of finding. Codes are stable so that
+// clients and CI pipelines can match on them.
+type FindingCode string
+
+const (
+	CodeKnownRangeOverlap   FindingCode = "known-range-overlap"
+	CodeLowPodsPerNode      FindingCode = "low-pods-per-node"
+	CodeSmallMachineNetwork FindingCode = "small-machine-network"
+	CodePublicRange         FindingCode = "public-range"
+)
+
+// Finding is something valid but suspicious about a request. Unlike
+// conflicts, findings never make a configuration unusable.
+type Finding struct {
+	Code        FindingCode    `json:"code"`
+	Severity    Severity       `json:"severity"`
+	Networks    []NamedNetwork `json:"networks"`
+	Message     string         `json:"message"`
+	Remediation string         `json:"remediation"`
+}
+
+// minPodsPerNode is the OpenShift default for the kubelet maxPods setting.
+const minPodsPerNode = 250
+
+// privateNetworks are the ranges that are not routed on the internet: RFC
+// 1918, the RFC 6598 shared space and IPv6 unique local addresses.
+var privateNetworks = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"}
+
+// checkFindings returns the findings for the networks of one family. Pods
+// per node is not checked for CNIs that allocate blocks, since a node claims
+// as many blocks as its pods need.
+func checkFindings(result *FamilyResult, clusterNetworks []NamedNetwork, service, machine NamedNetwork, cni CNI) ([]Finding, error) {
+	findings, err := checkKnownRanges(append(append([]NamedNetwork(nil), clusterNetworks...), service, machine)...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, clusterNetwork := range result.ClusterNetworks {
+		if allocatesBlocks(cni) || clusterNetwork.PodsPerNode.Cmp(big.NewInt(minPodsPerNode)) >= 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Code:        CodeLowPodsPerNode,
+			Severity:    SeverityWarning,
+			Networks:    []NamedNetwork{clusterNetworks[i]},
+			Message:     fmt.Sprintf("%s node subnets of /%d hold %s pods, fewer than the %d pods OpenShift schedules per node by default.", clusterNetwork.Name, clusterNetwork.NodePrefix, clusterNetwork.PodsPerNode, minPodsPerNode),
+			Remediation: "Use a smaller hostPrefix, or lower maxPods in a KubeletConfig to match the subnet.",
+		})
+	}
+
+	numNodes := result.NumNodes
+	if new(big.Int).Mul(numNodes.Have, big.NewInt(2)).Cmp(numNodes.Want) < 0 {
+		findings = append(findings, Finding{
+			Code:        CodeSmallMachineNetwork,
+			Severity:    SeverityWarning,
+			Networks:    []NamedNetwork{machine},
+			Message:     fmt.Sprintf("%s %s holds %s node IPs, less than half of the %s node subnets the cluster network provides.", machine.Name, machine.CIDR, numNodes.Have, numNodes.Want),
+			Remediation: "Use a larger machine network, or a smaller cluster network if the cluster will not grow beyond the machine network.",
+		})
+	}
+
+	for _, network := range append(append([]NamedNetwork(nil), clusterNetworks...), service) {
+		private, err := isPrivateCIDR(network.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		if private {
+			continue
+		}
+		findings = append(findings, Finding{
+			Code:        CodePublicRange,
+			Severity:    SeverityWarning,
+			Networks:    []NamedNetwork{network},
+			Message:     fmt.Sprintf("%s %s is publicly routable; the cluster cannot reach internet hosts that use these addresses.", network.Name, network.CIDR),
+			Remediation: "Use an RFC 1918 range for IPv4 or a unique local (fd00::/8) range for IPv6.",
+		})
+	}
+	return findings, nil
+}
+
+func isPrivateCIDR(cidr string) (bool, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+	ones, _ := ipNet.Mask.Size()
+	for _, private := range privateNetworks {
+		_, privateNet, _ := net.ParseCIDR(private)
+		privateOnes, _ := privateNet.Mask.Size()
+		if privateNet.Contains(ipNet.IP) && ones >= privateOnes {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasFinding reports whether any finding has one of the codes.
+func HasFinding(findings []Finding, codes ...FindingCode) bool {
+	for _, finding := range findings {
+		for _, code := range codes {
+			if finding.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -3,3 +3,5 @@ module github.com/kevydotvinu/onc
 go 1.20
 
 require github.com/aws/aws-lambda-go v1.41.0
+
+require gopkg.in/yaml.v3 v3.0.1
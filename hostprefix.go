@@ -0,0 +1,74 @@
+package onc
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// validateHostPrefix checks the hostPrefix of a cluster network entry: it
+// must be longer than the cluster network prefix, leave at least one pod
+// address after the CNI reservations and, for IPv6, be /64 as OpenShift
+// requires. The error reports the valid range. CNIs that allocate their own
+// block size have the blocks checked against the same range instead, and
+// errors name the CNI setting when one chose the size.
+func validateHostPrefix(name string, entry ClusterNetworkEntry, family IPFamily, nodePrefix int, reservations []Reservation, request Request, cni CNI) error {
+	_, ipNet, err := net.ParseCIDR(entry.CIDR)
+	if err != nil {
+		return invalidCIDR(name+".cidr", entry.CIDR)
+	}
+	ones, bits := ipNet.Mask.Size()
+	reserved := new(big.Int)
+	for _, reservation := range reservations {
+		reserved.Add(reserved, reservation.Count)
+	}
+	nodeLow, nodeHigh := ones+1, bits-ceilLog2(new(big.Int).Add(reserved, big.NewInt(1)))
+	nodeReason := fmt.Sprintf("longer than the /%d cluster network and leaving room for the %s addresses %s reserves per node", ones, reserved, cni.Name())
+
+	var v validation
+	low, high := ones+1, bits
+	reason := fmt.Sprintf("longer than the /%d cluster network", ones)
+	if nodePrefix == entry.HostPrefix {
+		high, reason = nodeHigh, nodeReason
+	}
+	if family == IPv6 {
+		if low < ipv6HostPrefix {
+			low = ipv6HostPrefix
+		}
+		if high > ipv6HostPrefix {
+			high = ipv6HostPrefix
+		}
+		reason = fmt.Sprintf("OpenShift requires /%d node subnets for IPv6", ipv6HostPrefix)
+	}
+
+	field := name + ".hostPrefix"
+	switch {
+	case low > high:
+		v.errs = append(v.errs, fieldError(name+".cidr", ErrTooSmall, entry.CIDR, "%s %s is too small for any hostPrefix; it must be a /%d or larger (%s)", name, entry.CIDR, high-1, reason))
+	case entry.HostPrefix < low || entry.HostPrefix > high:
+		v.errs = append(v.errs, fieldError(field, ErrOutOfRange, entry.HostPrefix, "%s %d is out of range for %s; it must be %s (%s)", field, entry.HostPrefix, entry.CIDR, prefixRange(low, high), reason))
+	}
+
+	if nodePrefix != entry.HostPrefix && (nodePrefix < nodeLow || nodePrefix > nodeHigh) {
+		nodeField := ""
+		if config, ok := cni.(NodePrefixConfig); ok {
+			nodeField = config.NodePrefixField(family, request)
+		}
+		switch {
+		case nodeField != "" && nodeLow <= nodeHigh:
+			v.errs = append(v.errs, fieldError(nodeField, ErrOutOfRange, nodePrefix, "%s %d is out of range for %s; it must be %s (%s)", nodeField, nodePrefix, entry.CIDR, prefixRange(nodeLow, nodeHigh), nodeReason))
+		case nodePrefix < nodeLow || nodeLow > nodeHigh:
+			v.errs = append(v.errs, fieldError(name+".cidr", ErrTooSmall, entry.CIDR, "%s %s is too small for the /%d blocks %s allocates per node", name, entry.CIDR, nodePrefix, cni.Name()))
+		default:
+			v.errs = append(v.errs, fieldError("cni", ErrUnsupported, cni.Name(), "%s allocates /%d blocks per node, which leave no room for its %s reserved addresses", cni.Name(), nodePrefix, reserved))
+		}
+	}
+	return v.err()
+}
+
+func prefixRange(low, high int) string {
+	if low == high {
+		return fmt.Sprint(low)
+	}
+	return fmt.Sprintf("between %d and %d", low, high)
+}
@@ -0,0 +1,279 @@
+package onc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InstallConfig holds the parts of an OpenShift install-config.yaml that
+// determine the cluster networks.
+type InstallConfig struct {
+	Networking InstallConfigNetworking `yaml:"networking"`
+	Platform   map[string]PlatformVIPs `yaml:"platform"`
+
+	// lines maps field paths such as networking.clusterNetwork[0].cidr to
+	// their line in the YAML document.
+	lines map[string]int
+}
+
+type InstallConfigNetworking struct {
+	NetworkType    string                        `yaml:"networkType"`
+	ClusterNetwork []ClusterNetworkEntry         `yaml:"clusterNetwork"`
+	ServiceNetwork []string                      `yaml:"serviceNetwork"`
+	MachineNetwork []InstallConfigMachineNetwork `yaml:"machineNetwork"`
+}
+
+type InstallConfigMachineNetwork struct {
+	CIDR string `yaml:"cidr"`
+}
+
+// PlatformVIPs are the API and ingress virtual IPs of on-premise platforms
+// such as baremetal, vsphere, openstack and nutanix, in both the current
+// list form and the deprecated single-value form.
+type PlatformVIPs struct {
+	APIVIPs     []string `yaml:"apiVIPs"`
+	APIVIP      string   `yaml:"apiVIP"`
+	IngressVIPs []string `yaml:"ingressVIPs"`
+	IngressVIP  string   `yaml:"ingressVIP"`
+}
+
+// defaultInstallMachineNetwork is the machine network the installer uses
+// when install-config leaves it out.
+const defaultInstallMachineNetwork = "10.0.0.0/16"
+
+// networkTypes maps install-config networkType values to CNI profiles.
+var networkTypes = map[string]string{
+	"OVNKubernetes": "ovn-kubernetes",
+	"OpenShiftSDN":  "openshift-sdn",
+	"Calico":        "calico",
+	"Cilium":        "cilium",
+	"Kuryr":         "kuryr",
+}
+
+// ParseInstallConfig reads an install-config.yaml document.
+func ParseInstallConfig(data []byte) (*InstallConfig, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	config := &InstallConfig{lines: make(map[string]int)}
+	if len(root.Content) == 0 {
+		return config, nil
+	}
+	if err := root.Content[0].Decode(config); err != nil {
+		return nil, err
+	}
+	recordLines(config.lines, "", root.Content[0])
+	return config, nil
+}
+
+// recordLines walks a YAML node and records the line of every value under
+// its field path.
+func recordLines(lines map[string]int, path string, node *yaml.Node) {
+	if path != "" {
+		lines[path] = node.Line
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			recordLines(lines, key, node.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			recordLines(lines, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	}
+}
+
+// Request converts the networking stanza into a calculator request. Networks
+// the install-config leaves out get the installer defaults.
+func (c *InstallConfig) Request() Request {
+	networking := c.Networking
+	request := Request{
+		Cni:             networkTypes[networking.NetworkType],
+		ClusterNetworks: networking.ClusterNetwork,
+		ServiceNetworks: networking.ServiceNetwork,
+	}
+	if request.Cni == "" {
+		request.Cni = networking.NetworkType
+	}
+	for _, machineNetwork := range networking.MachineNetwork {
+		request.MachineNetworks = append(request.MachineNetworks, machineNetwork.CIDR)
+	}
+	if len(request.MachineNetworks) == 0 {
+		request.MachineNetwork = defaultInstallMachineNetwork
+	}
+	return request
+}
+
+// CalculateInstallConfig parses an install-config.yaml, checks its platform
+// VIPs and calculates its networks, also checking them against exclusions.
+// Field errors name the install-config field and its line.
+func CalculateInstallConfig(data []byte, exclusions ...Exclusion) (*Response, error) {
+	config, err := ParseInstallConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return config.Calculate(exclusions...)
+}
+
+// Calculate checks the platform VIPs of a parsed install-config and
+// calculates its networks. The VIP and network errors are reported together.
+func (c *InstallConfig) Calculate(exclusions ...Exclusion) (*Response, error) {
+	request := c.Request()
+	request.Exclusions = exclusions
+	var v validation
+	response, err := CalculateNetwork(request)
+	if err := v.add(err); err != nil {
+		return nil, err
+	}
+	if err := v.add(c.validateVIPs(request)); err != nil {
+		return nil, err
+	}
+	if err := v.err(); err != nil {
+		return nil, c.locate(err)
+	}
+	return response, nil
+}
+
+// validateVIPs checks that every API and ingress VIP is an IP address in a
+// machine network and outside the cluster and service networks. Networks
+// that do not parse are left to the calculation to report.
+func (c *InstallConfig) validateVIPs(request Request) error {
+	if cni, err := LookupCNI(request.Cni); err == nil {
+		request = applyDefaults(request, cni)
+	}
+	var machineNets, reservedNets []*net.IPNet
+	for _, cidr := range request.machineNetworks() {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			machineNets = append(machineNets, ipNet)
+		}
+	}
+	var reservedNames []string
+	for i, clusterNetwork := range request.clusterNetworks() {
+		if _, ipNet, err := net.ParseCIDR(clusterNetwork.CIDR); err == nil {
+			reservedNets = append(reservedNets, ipNet)
+			reservedNames = append(reservedNames, fmt.Sprintf("clusterNetwork[%d]", i))
+		}
+	}
+	for i, cidr := range request.serviceNetworks() {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			reservedNets = append(reservedNets, ipNet)
+			reservedNames = append(reservedNames, fmt.Sprintf("serviceNetwork[%d]", i))
+		}
+	}
+
+	var platforms []string
+	for platform := range c.Platform {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	var v validation
+	for _, platform := range platforms {
+		for _, vip := range c.Platform[platform].vips() {
+			field := "platform." + platform + "." + vip.field
+			ip := net.ParseIP(vip.ip)
+			if ip == nil {
+				v.errs = append(v.errs, fieldError(field, ErrInvalidValue, vip.ip, "Invalid IP address: %s", vip.ip))
+				continue
+			}
+			inMachineNetwork := false
+			for _, machineNet := range machineNets {
+				inMachineNetwork = inMachineNetwork || machineNet.Contains(ip)
+			}
+			if !inMachineNetwork {
+				v.errs = append(v.errs, fieldError(field, ErrOutOfRange, vip.ip, "%s %s is not in the machine network %s", field, vip.ip, strings.Join(request.machineNetworks(), ",")))
+			}
+			for i, reservedNet := range reservedNets {
+				if reservedNet.Contains(ip) {
+					v.errs = append(v.errs, fieldError(field, ErrOutOfRange, vip.ip, "%s %s is inside %s %s", field, vip.ip, reservedNames[i], reservedNet))
+				}
+			}
+		}
+	}
+	return v.err()
+}
+
+type platformVIP struct{ field, ip string }
+
+func (p PlatformVIPs) vips() []platformVIP {
+	var vips []platformVIP
+	for i, ip := range p.APIVIPs {
+		vips = append(vips, platformVIP{fmt.Sprintf("apiVIPs[%d]", i), ip})
+	}
+	if p.APIVIP != "" {
+		vips = append(vips, platformVIP{"apiVIP", p.APIVIP})
+	}
+	for i, ip := range p.IngressVIPs {
+		vips = append(vips, platformVIP{fmt.Sprintf("ingressVIPs[%d]", i), ip})
+	}
+	if p.IngressVIP != "" {
+		vips = append(vips, platformVIP{"ingressVIP", p.IngressVIP})
+	}
+	return vips
+}
+
+// installConfigFields maps request field paths to install-config paths.
+var installConfigFields = []struct{ request, installConfig string }{
+	{"clusterNetwork", "networking.clusterNetwork"},
+	{"serviceNetwork", "networking.serviceNetwork"},
+	{"machineNetwork", "networking.machineNetwork"},
+	{"cni", "networking.networkType"},
+}
+
+// locate rewrites the field errors in err to install-config paths and adds
+// the line of each field, or of its closest parent in the document.
+func (c *InstallConfig) locate(err error) error {
+	var validationErr *ValidationError
+	var fieldErr *FieldError
+	var fieldErrs []*FieldError
+	switch {
+	case errors.As(err, &validationErr):
+		fieldErrs = validationErr.Errors
+	case errors.As(err, &fieldErr):
+		fieldErrs = []*FieldError{fieldErr}
+	default:
+		return err
+	}
+
+	var located []*FieldError
+	for _, fieldErr := range fieldErrs {
+		located = append(located, c.locateField(*fieldErr))
+	}
+	return &ValidationError{Errors: located}
+}
+
+func (c *InstallConfig) locateField(fieldErr FieldError) *FieldError {
+	field := fieldErr.Field
+	if strings.HasPrefix(field, "machineNetwork[") {
+		field += ".cidr"
+	}
+	for _, mapping := range installConfigFields {
+		if strings.HasPrefix(field, mapping.request) {
+			field = mapping.installConfig + strings.TrimPrefix(field, mapping.request)
+		}
+	}
+	fieldErr.Field = field
+	for path := field; path != ""; {
+		if line, ok := c.lines[path]; ok {
+			fieldErr.Line = line
+			break
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return &fieldErr
+}
@@ -0,0 +1,84 @@
+package onc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCalculateInstallConfigErrorLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		installConfig string
+		lines         map[string]int
+	}{
+		{
+			name: "machine network families",
+			installConfig: `apiVersion: v1
+networking:
+  clusterNetwork:
+  - cidr: 10.128.0.0/14
+    hostPrefix: 23
+  - cidr: fd01::/48
+    hostPrefix: 64
+  serviceNetwork:
+  - 172.30.0.0/16
+  - fd02::/112
+  machineNetwork:
+  - cidr: fd00::/64
+  - cidr: 10.0.0.0/16
+`,
+			lines: map[string]int{"networking.machineNetwork": 12},
+		},
+		{
+			name: "hostPrefix and service network",
+			installConfig: `apiVersion: v1
+networking:
+  networkType: OVNKubernetes
+  clusterNetwork:
+  - cidr: 10.128.0.0/14
+    hostPrefix: 10
+  serviceNetwork:
+  - 172.30.0.0/30
+  machineNetwork:
+  - cidr: 10.0.0.0/16
+`,
+			lines: map[string]int{
+				"networking.clusterNetwork[0].hostPrefix": 6,
+				"networking.serviceNetwork[0]":            8,
+			},
+		},
+		{
+			name: "machine network CIDR",
+			installConfig: `apiVersion: v1
+networking:
+  machineNetwork:
+  - cidr: 10.0.0.0/16
+  - cidr: 10.1.0.0/33
+`,
+			lines: map[string]int{"networking.machineNetwork[1].cidr": 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CalculateInstallConfig([]byte(tt.installConfig))
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("CalculateInstallConfig error = %v, want a validation error", err)
+			}
+			lines := make(map[string]int)
+			for _, fieldErr := range validationErr.Errors {
+				lines[fieldErr.Field] = fieldErr.Line
+			}
+			for field, line := range tt.lines {
+				if got, ok := lines[field]; !ok {
+					t.Errorf("no error on %s, got %v", field, lines)
+				} else if got != line {
+					t.Errorf("%s reported on line %d, want %d", field, got, line)
+				}
+			}
+			if len(lines) != len(tt.lines) {
+				t.Errorf("error lines = %v, want %v", lines, tt.lines)
+			}
+		})
+	}
+}
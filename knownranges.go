@@ -0,0 +1,110 @@
+package onc
+
+import (
+	"fmt"
+	"net"
+)
+
+// KnownRange is an address range that other software claims by default.
+// Overlapping one is valid but tends to break traffic from or to the hosts
+// that use it; Impact says how.
+type KnownRange struct {
+	Name   string `json:"name"`
+	CIDR   string `json:"cidr"`
+	Impact string `json:"impact"`
+}
+
+var knownRanges = []KnownRange{
+	{
+		Name:   "Docker default bridge",
+		CIDR:   "172.17.0.0/16",
+		Impact: "hosts running Docker, such as bastions and CI runners, route these addresses to the local docker0 bridge and cannot reach the cluster",
+	},
+	{
+		Name:   "Podman default network",
+		CIDR:   "10.88.0.0/16",
+		Impact: "hosts running Podman route these addresses to the local podman bridge and cannot reach the cluster",
+	},
+	{
+		Name:   "libvirt default network",
+		CIDR:   "192.168.122.0/24",
+		Impact: "hypervisors and developer machines running libvirt route these addresses to virbr0",
+	},
+	{
+		Name:   "AWS VPC DNS",
+		CIDR:   "169.254.169.253/32",
+		Impact: "instances on AWS resolve names through this address and lose DNS if it is routed into the cluster",
+	},
+	{
+		Name:   "AWS VPC DNS",
+		CIDR:   "fd00:ec2::253/128",
+		Impact: "instances on AWS resolve names through this address and lose DNS if it is routed into the cluster",
+	},
+	{
+		Name:   "link-local",
+		CIDR:   "169.254.0.0/16",
+		Impact: "link-local addresses are never routed and are used for cloud metadata services and the OVN masquerade subnet",
+	},
+	{
+		Name:   "link-local",
+		CIDR:   "fe80::/10",
+		Impact: "link-local addresses are never routed and exist on every IPv6 interface",
+	},
+	{
+		Name:   "carrier-grade NAT",
+		CIDR:   "100.64.0.0/10",
+		Impact: "ISPs, VPN overlays such as Tailscale and the OVN-Kubernetes internal subnets use this shared address space",
+	},
+	{
+		Name:   "Kubernetes default service network",
+		CIDR:   "10.96.0.0/12",
+		Impact: "clusters installed with kubeadm defaults use it, which prevents connecting them with multi-cluster networking",
+	},
+	{
+		Name:   "Flannel default pod network",
+		CIDR:   "10.244.0.0/16",
+		Impact: "clusters installed with upstream Flannel defaults use it, which prevents connecting them with multi-cluster networking",
+	},
+	{
+		Name:   "k3s default pod network",
+		CIDR:   "10.42.0.0/16",
+		Impact: "k3s and RKE2 clusters use it by default, which prevents connecting them with multi-cluster networking",
+	},
+	{
+		Name:   "k3s default service network",
+		CIDR:   "10.43.0.0/16",
+		Impact: "k3s and RKE2 clusters use it by default, which prevents connecting them with multi-cluster networking",
+	},
+}
+
+// KnownRanges returns the catalogue of commonly clashing default ranges.
+func KnownRanges() []KnownRange {
+	return append([]KnownRange(nil), knownRanges...)
+}
+
+// checkKnownRanges returns a finding for every network that overlaps a
+// catalogued range.
+func checkKnownRanges(networks ...NamedNetwork) ([]Finding, error) {
+	findings := []Finding{}
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		for _, known := range knownRanges {
+			_, knownNet, _ := net.ParseCIDR(known.CIDR)
+			overlap := overlapCIDR(ipNet, knownNet)
+			if overlap == nil {
+				continue
+			}
+			findings = append(findings, Finding{
+				Code:        CodeKnownRangeOverlap,
+				Severity:    SeverityWarning,
+				Networks:    []NamedNetwork{network, {Name: known.Name, Role: RoleKnownRange, CIDR: known.CIDR}},
+				Message:     fmt.Sprintf("%s %s overlaps the %s range %s in %s; %s.", network.Name, network.CIDR, known.Name, known.CIDR, overlap, known.Impact),
+				Remediation: fmt.Sprintf("Move %s out of %s unless no host that uses the %s range needs to reach the cluster.", network.Name, known.CIDR, known.Name),
+			})
+		}
+	}
+	return findings, nil
+}
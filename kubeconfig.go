@@ -0,0 +1,217 @@
+package onc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubeconfig holds the parts of a kubeconfig file needed to reach the API
+// server. Exec and auth-provider plugins are not supported; use a token or
+// client certificate, for example from "oc login".
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+			TLSServerName            string `yaml:"tls-server-name"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// KubeClient is a ClusterAPI for an API server configured in a kubeconfig.
+type KubeClient struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+// DefaultKubeconfig returns the kubeconfig kubectl and oc use: the first
+// file in $KUBECONFIG, or ~/.kube/config.
+func DefaultKubeconfig() string {
+	if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 && paths[0] != "" {
+		return paths[0]
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// LoadKubeconfig creates a client for a context of a kubeconfig file, or
+// its current context when contextName is empty.
+func LoadKubeconfig(path, contextName string) (*KubeClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	// Relative certificate and token files are relative to the kubeconfig.
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(filepath.Dir(path), file)
+	}
+
+	var clusterName, userName string
+	found := false
+	for _, context := range config.Contexts {
+		if context.Name == contextName {
+			clusterName, userName, found = context.Context.Cluster, context.Context.User, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: context %q not found", path, contextName)
+	}
+
+	client := &KubeClient{}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	found = false
+	for _, cluster := range config.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+		found = true
+		client.server = strings.TrimSuffix(cluster.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cluster.Cluster.InsecureSkipTLSVerify
+		tlsConfig.ServerName = cluster.Cluster.TLSServerName
+		ca, err := readKubeconfigData(cluster.Cluster.CertificateAuthorityData, resolve(cluster.Cluster.CertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: certificate authority: %w", clusterName, err)
+		}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("cluster %s: no certificates in the certificate authority", clusterName)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: cluster %q not found", path, clusterName)
+	}
+
+	found = false
+	for _, user := range config.Users {
+		if user.Name != userName {
+			continue
+		}
+		found = true
+		client.token = user.User.Token
+		if client.token == "" && user.User.TokenFile != "" {
+			token, err := os.ReadFile(resolve(user.User.TokenFile))
+			if err != nil {
+				return nil, fmt.Errorf("user %s: %w", userName, err)
+			}
+			client.token = strings.TrimSpace(string(token))
+		}
+		cert, err := readKubeconfigData(user.User.ClientCertificateData, resolve(user.User.ClientCertificate))
+		if err != nil {
+			return nil, fmt.Errorf("user %s: client certificate: %w", userName, err)
+		}
+		key, err := readKubeconfigData(user.User.ClientKeyData, resolve(user.User.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("user %s: client key: %w", userName, err)
+		}
+		if cert != nil && key != nil {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("user %s: %w", userName, err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	// A context without a user is anonymous on purpose; a user that is
+	// missing would silently be.
+	if !found && userName != "" {
+		return nil, fmt.Errorf("%s: user %q not found", path, userName)
+	}
+
+	client.client = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+	}
+	return client, nil
+}
+
+// readKubeconfigData returns base64 inline data, or else the file contents.
+func readKubeconfigData(data, file string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
+
+// Get reads a path from the API server as JSON.
+func (c *KubeClient) Get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.server+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// The API server explains errors in a Status resource.
+		var status struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &status)
+		path, _, _ = strings.Cut(path, "?")
+		return nil, &APIError{Path: path, StatusCode: resp.StatusCode, Message: status.Message}
+	}
+	return body, nil
+}
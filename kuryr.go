@@ -0,0 +1,50 @@
+package onc
+
+// kuryr creates an OpenStack Neutron subnet of hostPrefix size per
+// namespace rather than per node, so the cluster network limits namespaces
+// and pods-per-node is the pods of a namespace.
+type kuryr struct{}
+
+func init() {
+	RegisterCNI(kuryr{})
+}
+
+func (kuryr) Name() string {
+	return "kuryr"
+}
+
+func (kuryr) SupportedFamilies() []IPFamily {
+	return []IPFamily{IPv4}
+}
+
+func (kuryr) Defaults() CNIDefaults {
+	return openshiftDefaults
+}
+
+func (kuryr) Supported(release Release) error {
+	if release.Kuryr {
+		return nil
+	}
+	if release.Version == "" {
+		return fieldError("cni", ErrUnsupported, "kuryr", "kuryr was removed in OpenShift 4.%d; set an earlier openshiftVersion", kuryrRemovedVersion)
+	}
+	return fieldError("cni", ErrUnsupported, "kuryr", "kuryr is not supported in OpenShift %s; it was removed in 4.%d", release.Version, kuryrRemovedVersion)
+}
+
+func (kuryr) AllocatesPerNamespace() bool {
+	return true
+}
+
+func (kuryr) NodePrefix(family IPFamily, hostPrefix int, request Request) (int, error) {
+	return hostPrefix, nil
+}
+
+// NodeReservations adds the Neutron router port, the gateway of every
+// namespace subnet, to the subnet edges.
+func (kuryr) NodeReservations(family IPFamily, request Request) []Reservation {
+	return append(subnetEdges(family), reserveAt(AddressGateway, "Neutron router port", 1))
+}
+
+func (kuryr) InternalSubnets(family IPFamily, request Request, release Release) ([]NamedNetwork, error) {
+	return nil, nil
+}
@@ -0,0 +1,138 @@
+package onc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ClusterAPI reads resources from the Kubernetes API by path, such as
+// /api/v1/nodes. KubeClient talks to a real cluster; StaticCluster serves
+// recorded responses, so audits can run in CI without one.
+type ClusterAPI interface {
+	Get(ctx context.Context, path string) ([]byte, error)
+}
+
+// APIError is a response of the API server other than 200 OK.
+type APIError struct {
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("GET %s: %d %s: %s", e.Path, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	}
+	return fmt.Sprintf("GET %s: %d %s", e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// StaticCluster is a ClusterAPI that answers from recorded responses keyed
+// by path, for example the output of "oc get --raw /api/v1/nodes". A key
+// with a query answers only that request, such as one page of a list;
+// otherwise the query is ignored. Missing paths are 404 Not Found.
+type StaticCluster map[string][]byte
+
+func (c StaticCluster) Get(ctx context.Context, path string) ([]byte, error) {
+	if data, ok := c[path]; ok {
+		return data, nil
+	}
+	path, _, _ = strings.Cut(path, "?")
+	data, ok := c[path]
+	if !ok {
+		return nil, &APIError{Path: path, StatusCode: http.StatusNotFound}
+	}
+	return data, nil
+}
+
+// clusterResource is a path read for an audit. Optional resources may be
+// missing or forbidden: HostSubnets exist only with openshift-sdn and the
+// install-config ConfigMap needs access to kube-system.
+type clusterResource struct {
+	path     string
+	list     bool
+	optional bool
+}
+
+var clusterResources = []clusterResource{
+	{path: "/apis/config.openshift.io/v1/networks/cluster"},
+	{path: "/apis/operator.openshift.io/v1/networks/cluster", optional: true},
+	{path: "/apis/config.openshift.io/v1/clusterversions/version", optional: true},
+	{path: "/api/v1/namespaces/kube-system/configmaps/" + installConfigMapName, optional: true},
+	{path: "/api/v1/nodes", list: true},
+	{path: "/apis/network.openshift.io/v1/hostsubnets", list: true, optional: true},
+	{path: "/api/v1/services", list: true},
+	{path: "/api/v1/pods", list: true},
+}
+
+// listPageSize is the number of items requested per page of a list.
+const listPageSize = 500
+
+// ReadCluster reads the cluster state for an audit through the API: the
+// Network resources, ClusterVersion, install-config, Nodes, HostSubnets,
+// Services and Pods.
+func ReadCluster(ctx context.Context, api ClusterAPI) (*ClusterState, error) {
+	state := &ClusterState{Services: []ClusterService{}, Pods: []ClusterPod{}}
+	for _, resource := range clusterResources {
+		err := readResource(ctx, api, state, resource)
+		var apiErr *APIError
+		if resource.optional && errors.As(err, &apiErr) &&
+			(apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusForbidden) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortNodes(state.Nodes)
+	return state, nil
+}
+
+// readResource adds a resource to the state, following the continue token
+// of lists so large clusters are read a page at a time.
+func readResource(ctx context.Context, api ClusterAPI, state *ClusterState, resource clusterResource) error {
+	if !resource.list {
+		data, err := api.Get(ctx, resource.path)
+		if err != nil {
+			return err
+		}
+		return state.AddResources(data)
+	}
+	query := url.Values{"limit": {fmt.Sprint(listPageSize)}}
+	for {
+		data, err := api.Get(ctx, resource.path+"?"+query.Encode())
+		if err != nil {
+			return err
+		}
+		if err := state.AddResources(data); err != nil {
+			return fmt.Errorf("%s: %w", resource.path, err)
+		}
+		var list struct {
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(data, &list); err != nil || list.Metadata.Continue == "" {
+			return nil
+		}
+		query.Set("continue", list.Metadata.Continue)
+	}
+}
+
+// AuditCluster reads the state of a running cluster and audits it. The
+// machine networks override those of the install-config ConfigMap, which
+// clusters installed without the installer do not have.
+func AuditCluster(ctx context.Context, api ClusterAPI, machineNetworks ...string) (*AuditReport, error) {
+	state, err := ReadCluster(ctx, api)
+	if err != nil {
+		return nil, err
+	}
+	if len(machineNetworks) > 0 {
+		state.MachineNetworks = machineNetworks
+	}
+	return Audit(state)
+}
@@ -0,0 +1,132 @@
+package onc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// forbiddenCluster answers 403 Forbidden for some paths, as the API server
+// does for users without access to them.
+type forbiddenCluster struct {
+	StaticCluster
+	forbidden []string
+}
+
+func (c forbiddenCluster) Get(ctx context.Context, path string) ([]byte, error) {
+	resource, _, _ := strings.Cut(path, "?")
+	if containsString(c.forbidden, resource) {
+		return nil, &APIError{Path: resource, StatusCode: http.StatusForbidden}
+	}
+	return c.StaticCluster.Get(ctx, path)
+}
+
+// liveCluster is an OVN-Kubernetes cluster with two nodes listed over two
+// pages. The operator Network, HostSubnets and install-config are missing.
+func liveCluster() StaticCluster {
+	return StaticCluster{
+		"/apis/config.openshift.io/v1/networks/cluster": []byte(`{"apiVersion":"config.openshift.io/v1","kind":"Network","metadata":{"name":"cluster"},
+			"status":{"clusterNetwork":[{"cidr":"10.128.0.0/14","hostPrefix":23}],"serviceNetwork":["172.30.0.0/16"],"networkType":"OVNKubernetes"}}`),
+		"/apis/config.openshift.io/v1/clusterversions/version": []byte(`{"apiVersion":"config.openshift.io/v1","kind":"ClusterVersion","metadata":{"name":"version"},
+			"status":{"desired":{"version":"4.16.3"}}}`),
+		"/api/v1/nodes?limit=500": []byte(`{"apiVersion":"v1","kind":"NodeList","metadata":{"continue":"page2"},"items":[
+			{"metadata":{"name":"node-a","annotations":{"k8s.ovn.org/node-subnets":"{\"default\":[\"10.128.0.0/23\"]}"}},
+			 "status":{"addresses":[{"type":"InternalIP","address":"10.0.0.10"}]}}]}`),
+		"/api/v1/nodes?continue=page2&limit=500": []byte(`{"apiVersion":"v1","kind":"NodeList","metadata":{},"items":[
+			{"metadata":{"name":"node-b","annotations":{"k8s.ovn.org/node-subnets":"{\"default\":[\"10.128.2.0/23\"]}"}},
+			 "status":{"addresses":[{"type":"InternalIP","address":"10.0.0.11"}]}}]}`),
+		"/api/v1/services": []byte(`{"apiVersion":"v1","kind":"ServiceList","metadata":{},"items":[
+			{"metadata":{"namespace":"default","name":"kubernetes"},"spec":{"clusterIP":"172.30.0.1","clusterIPs":["172.30.0.1"]}},
+			{"metadata":{"namespace":"app","name":"web"},"spec":{"clusterIP":"172.30.5.5","clusterIPs":["172.30.5.5"]}},
+			{"metadata":{"namespace":"app","name":"headless"},"spec":{"clusterIP":"None","clusterIPs":["None"]}}]}`),
+		"/api/v1/pods": []byte(`{"apiVersion":"v1","kind":"PodList","metadata":{},"items":[
+			{"metadata":{"namespace":"app","name":"web"},"spec":{"nodeName":"node-a"},"status":{"phase":"Running"}},
+			{"metadata":{"namespace":"openshift-ovn-kubernetes","name":"ovnkube-node"},"spec":{"nodeName":"node-a","hostNetwork":true},"status":{"phase":"Running"}},
+			{"metadata":{"namespace":"app","name":"job"},"spec":{"nodeName":"node-b"},"status":{"phase":"Succeeded"}}]}`),
+	}
+}
+
+func TestAuditCluster(t *testing.T) {
+	api := forbiddenCluster{StaticCluster: liveCluster(), forbidden: []string{"/apis/network.openshift.io/v1/hostsubnets"}}
+	report, err := AuditCluster(context.Background(), api, "10.0.0.0/16")
+	if err != nil {
+		t.Fatalf("AuditCluster: %v", err)
+	}
+	if report.Cni != "ovn-kubernetes" || report.OpenShiftVersion != "4.16" {
+		t.Errorf("cni %s version %s, want ovn-kubernetes 4.16", report.Cni, report.OpenShiftVersion)
+	}
+	if report.Nodes != 2 || report.NodeSubnets[0].Allocated.Int64() != 2 {
+		t.Errorf("got %d nodes with %s subnets, want both pages of nodes", report.Nodes, report.NodeSubnets[0].Allocated)
+	}
+	if used := report.ServiceIPs[0].Used.Int64(); used != 1 {
+		t.Errorf("service IPs used = %d, want 1 without the kubernetes and headless services", used)
+	}
+	pods := make(map[string]int)
+	for _, usage := range report.Pods {
+		pods[usage.Node] = usage.Pods
+	}
+	if pods["node-a"] != 1 || pods["node-b"] != 0 {
+		t.Errorf("pods per node = %v, want 1 on node-a without the host network pod and none on node-b", pods)
+	}
+	if len(report.Conflicts) != 0 {
+		t.Errorf("unexpected conflicts: %v", report.Conflicts)
+	}
+}
+
+func TestAuditClusterRequiredResources(t *testing.T) {
+	api := forbiddenCluster{StaticCluster: liveCluster(), forbidden: []string{"/api/v1/nodes"}}
+	_, err := AuditCluster(context.Background(), api, "10.0.0.0/16")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("AuditCluster error = %v, want 403 Forbidden for the nodes", err)
+	}
+
+	delete(api.StaticCluster, "/apis/config.openshift.io/v1/networks/cluster")
+	api.forbidden = nil
+	_, err = AuditCluster(context.Background(), api, "10.0.0.0/16")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("AuditCluster error = %v, want 404 Not Found for the Network", err)
+	}
+}
+
+func TestLoadKubeconfig(t *testing.T) {
+	const config = `apiVersion: v1
+kind: Config
+current-context: admin
+clusters:
+- name: cluster
+  cluster:
+    server: https://api.example.com:6443/
+contexts:
+- name: admin
+  context:
+    cluster: cluster
+    user: admin
+- name: typo
+  context:
+    cluster: cluster
+    user: admn
+users:
+- name: admin
+  user:
+    token: sha256~secret
+`
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := LoadKubeconfig(path, "")
+	if err != nil {
+		t.Fatalf("LoadKubeconfig: %v", err)
+	}
+	if client.server != "https://api.example.com:6443" || client.token != "sha256~secret" {
+		t.Errorf("server %q token %q, want the admin context", client.server, client.token)
+	}
+	if _, err := LoadKubeconfig(path, "typo"); err == nil || !strings.Contains(err.Error(), `user "admn" not found`) {
+		t.Errorf("LoadKubeconfig error = %v, want the missing user", err)
+	}
+}
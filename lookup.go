@@ -0,0 +1,68 @@
+package onc
+
+import (
+	"math/big"
+	"net"
+	"strings"
+)
+
+type PodIPRequest struct {
+	Request
+	IP string `json:"ip"`
+}
+
+// PodIPLookup locates an address in the node subnets. Index counts node
+// subnets across the cluster networks, as in ListNodeSubnets.
+type PodIPLookup struct {
+	IP             string      `json:"ip"`
+	Index          *big.Int    `json:"index"`
+	ClusterNetwork string      `json:"cluster-network"`
+	NodeSubnet     string      `json:"node-subnet"`
+	Role           AddressRole `json:"role"`
+	Description    string      `json:"description"`
+}
+
+// LookupPodIP returns the node subnet owning an IP and the role of the
+// address in it.
+func LookupPodIP(request PodIPRequest) (*PodIPLookup, error) {
+	ip := net.ParseIP(request.IP)
+	if ip == nil {
+		return nil, fieldError("ip", ErrInvalidValue, request.IP, "Invalid IP address: %s", request.IP)
+	}
+	pools, _, err := nodeSubnetPools(request.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	index := new(big.Int)
+	var cidrs []string
+	for _, pool := range pools {
+		cidrs = append(cidrs, pool.ipNet.String())
+		if !pool.ipNet.Contains(ip) {
+			index.Add(index, pool.count)
+			continue
+		}
+		_, bits := pool.ipNet.Mask.Size()
+		offset := new(big.Int).Sub(ipToInt(ip), ipToInt(pool.ipNet.IP))
+		n := new(big.Int).Rsh(offset, uint(bits-pool.nodePrefix))
+		subnet := nthSubnet(pool.ipNet, pool.nodePrefix, n)
+		lookup := &PodIPLookup{
+			IP:             ip.String(),
+			Index:          index.Add(index, n),
+			ClusterNetwork: pool.name,
+			NodeSubnet:     subnet.String(),
+			Role:           AddressPod,
+			Description:    "pod address",
+		}
+		hostOffset := new(big.Int).Sub(ipToInt(ip), ipToInt(subnet.IP))
+		for _, reservation := range pool.reservations {
+			if reservation.Offset != nil && reservationOffset(reservation, cidrSize(subnet)).Cmp(hostOffset) == 0 {
+				lookup.Role = reservation.Role
+				lookup.Description = reservation.Reason
+				break
+			}
+		}
+		return lookup, nil
+	}
+	return nil, fieldError("ip", ErrOutOfRange, request.IP, "IP %s is outside the cluster network %s", ip, strings.Join(cidrs, ","))
+}
@@ -0,0 +1,43 @@
+package onc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLookupPodIP(t *testing.T) {
+	tests := []struct {
+		ip             string
+		index          string
+		clusterNetwork string
+		nodeSubnet     string
+		role           AddressRole
+	}{
+		{"10.128.2.17", "2", "clusterNetwork[0]", "10.128.2.0/24", AddressPod},
+		{"10.128.3.255", "3", "clusterNetwork[0]", "10.128.3.0/24", AddressBroadcast},
+		{"10.132.0.129", "5", "clusterNetwork[1]", "10.132.0.128/25", AddressGateway},
+		{"10.132.1.2", "6", "clusterNetwork[1]", "10.132.1.0/25", AddressManagementPort},
+		{"10.132.1.128", "7", "clusterNetwork[1]", "10.132.1.128/25", AddressNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			lookup, err := LookupPodIP(PodIPRequest{Request: twoPoolRequest, IP: tt.ip})
+			if err != nil {
+				t.Fatalf("LookupPodIP: %v", err)
+			}
+			if lookup.Index.String() != tt.index || lookup.ClusterNetwork != tt.clusterNetwork || lookup.NodeSubnet != tt.nodeSubnet || lookup.Role != tt.role {
+				t.Errorf("got index %s %s %s %s, want index %s %s %s %s",
+					lookup.Index, lookup.ClusterNetwork, lookup.NodeSubnet, lookup.Role,
+					tt.index, tt.clusterNetwork, tt.nodeSubnet, tt.role)
+			}
+		})
+	}
+}
+
+func TestLookupPodIPOutsideClusterNetwork(t *testing.T) {
+	_, err := LookupPodIP(PodIPRequest{Request: twoPoolRequest, IP: "10.200.0.1"})
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "ip" || fieldErr.Code != ErrOutOfRange {
+		t.Errorf("LookupPodIP error = %v, want ip %s", err, ErrOutOfRange)
+	}
+}
@@ -0,0 +1,270 @@
+package onc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ovnNodeSubnetsAnnotation = "k8s.ovn.org/node-subnets"
+	installConfigMapName     = "cluster-config-v1"
+	// kubeSystemConfigMaps is where "oc adm inspect" writes the ConfigMapList
+	// of the kube-system namespace, which holds the install-config.
+	kubeSystemConfigMaps = "namespaces/kube-system/core/configmaps.yaml"
+)
+
+type kubeMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type kubeObject struct {
+	APIVersion string       `yaml:"apiVersion"`
+	Kind       string       `yaml:"kind"`
+	Metadata   kubeMetadata `yaml:"metadata"`
+	Items      []yaml.Node  `yaml:"items"`
+}
+
+type kubeNode struct {
+	Metadata kubeMetadata `yaml:"metadata"`
+	Status   struct {
+		Addresses []struct {
+			Type    string `yaml:"type"`
+			Address string `yaml:"address"`
+		} `yaml:"addresses"`
+	} `yaml:"status"`
+}
+
+// hostSubnet is the openshift-sdn record of the subnet of a node.
+type hostSubnet struct {
+	Host   string `yaml:"host"`
+	Subnet string `yaml:"subnet"`
+}
+
+type kubePod struct {
+	Metadata kubeMetadata `yaml:"metadata"`
+	Spec     struct {
+		NodeName    string `yaml:"nodeName"`
+		HostNetwork bool   `yaml:"hostNetwork"`
+	} `yaml:"spec"`
+	Status struct {
+		Phase string `yaml:"phase"`
+	} `yaml:"status"`
+}
+
+type kubeService struct {
+	Metadata kubeMetadata `yaml:"metadata"`
+	Spec     struct {
+		ClusterIP  string   `yaml:"clusterIP"`
+		ClusterIPs []string `yaml:"clusterIPs"`
+	} `yaml:"spec"`
+}
+
+type kubeConfigMap struct {
+	Data map[string]string `yaml:"data"`
+}
+
+type clusterVersion struct {
+	Status struct {
+		Desired struct {
+			Version string `yaml:"version"`
+		} `yaml:"desired"`
+	} `yaml:"status"`
+}
+
+// LoadMustGather reads the cluster state from a must-gather directory: the
+// Network resources, the Nodes with their OVN-Kubernetes subnet annotation
+// or openshift-sdn HostSubnets, the ClusterVersion and, when gathered, the
+// install-config for the machine network.
+func LoadMustGather(dir string) (*ClusterState, error) {
+	state := &ClusterState{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isMustGatherResource(path) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := state.AddResources(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortNodes(state.Nodes)
+	return state, nil
+}
+
+// isMustGatherResource selects the cluster-scoped resources and the
+// install-config ConfigMap, on its own or in the kube-system ConfigMaps;
+// the rest of a must-gather is logs and
+// namespaced resources the audit does not need.
+func isMustGatherResource(path string) bool {
+	ext := filepath.Ext(path)
+	if ext != ".yaml" && ext != ".yml" {
+		return false
+	}
+	if strings.TrimSuffix(filepath.Base(path), ext) == installConfigMapName {
+		return true
+	}
+	path = filepath.ToSlash(path)
+	return strings.HasSuffix(path, "/"+kubeSystemConfigMaps) || strings.Contains(path, "/cluster-scoped-resources/")
+}
+
+// AddResources adds the resources in a YAML or JSON document stream to the
+// state. Lists are expanded and resources the audit does not use are
+// ignored.
+func (s *ClusterState) AddResources(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := s.addResource(&node, "", ""); err != nil {
+			return err
+		}
+	}
+}
+
+// addResource adds one resource. Items of lists returned by the API server
+// carry no apiVersion and kind; they are taken from the list instead.
+func (s *ClusterState) addResource(node *yaml.Node, apiVersion, kind string) error {
+	var object kubeObject
+	if err := node.Decode(&object); err != nil {
+		return err
+	}
+	if object.Kind == "" {
+		object.APIVersion, object.Kind = apiVersion, kind
+	}
+	switch {
+	case strings.HasSuffix(object.Kind, "List"):
+		for i := range object.Items {
+			if err := s.addResource(&object.Items[i], object.APIVersion, strings.TrimSuffix(object.Kind, "List")); err != nil {
+				return err
+			}
+		}
+	case object.Kind == "Network":
+		if s.Network == nil {
+			s.Network = &NetworkResources{}
+		}
+		return s.Network.addAs(node, object.APIVersion)
+	case object.Kind == "Node" && object.APIVersion == "v1":
+		var kn kubeNode
+		if err := node.Decode(&kn); err != nil {
+			return err
+		}
+		clusterNode := ClusterNode{Name: kn.Metadata.Name}
+		for _, address := range kn.Status.Addresses {
+			if address.Type == "InternalIP" {
+				clusterNode.InternalIPs = append(clusterNode.InternalIPs, address.Address)
+			}
+		}
+		if annotation, ok := kn.Metadata.Annotations[ovnNodeSubnetsAnnotation]; ok {
+			subnets, err := parseOVNNodeSubnets(annotation)
+			if err != nil {
+				return fmt.Errorf("node %s: invalid %s annotation: %v", clusterNode.Name, ovnNodeSubnetsAnnotation, err)
+			}
+			clusterNode.Subnets = subnets
+		}
+		s.node(clusterNode.Name).merge(clusterNode)
+	case object.Kind == "HostSubnet" && object.APIVersion == "network.openshift.io/v1":
+		var subnet hostSubnet
+		if err := node.Decode(&subnet); err != nil {
+			return err
+		}
+		s.node(subnet.Host).merge(ClusterNode{Subnets: []string{subnet.Subnet}})
+	case object.Kind == "Pod" && object.APIVersion == "v1":
+		var pod kubePod
+		if err := node.Decode(&pod); err != nil {
+			return err
+		}
+		phase := pod.Status.Phase
+		if pod.Spec.NodeName != "" && !pod.Spec.HostNetwork && phase != "Succeeded" && phase != "Failed" {
+			s.Pods = append(s.Pods, ClusterPod{Namespace: pod.Metadata.Namespace, Name: pod.Metadata.Name, Node: pod.Spec.NodeName})
+		}
+	case object.Kind == "Service" && object.APIVersion == "v1":
+		var service kubeService
+		if err := node.Decode(&service); err != nil {
+			return err
+		}
+		clusterIPs := service.Spec.ClusterIPs
+		if len(clusterIPs) == 0 && service.Spec.ClusterIP != "" {
+			clusterIPs = []string{service.Spec.ClusterIP}
+		}
+		if len(clusterIPs) > 0 && clusterIPs[0] != "None" {
+			s.Services = append(s.Services, ClusterService{Namespace: service.Metadata.Namespace, Name: service.Metadata.Name, ClusterIPs: clusterIPs})
+		}
+	case object.Kind == "ClusterVersion" && object.Metadata.Name == "version":
+		var version clusterVersion
+		if err := node.Decode(&version); err != nil {
+			return err
+		}
+		s.OpenShiftVersion = version.Status.Desired.Version
+	case object.Kind == "ConfigMap" && object.Metadata.Name == installConfigMapName:
+		var configMap kubeConfigMap
+		if err := node.Decode(&configMap); err != nil {
+			return err
+		}
+		config, err := ParseInstallConfig([]byte(configMap.Data["install-config"]))
+		if err != nil {
+			return fmt.Errorf("%s install-config: %w", installConfigMapName, err)
+		}
+		if len(s.MachineNetworks) == 0 {
+			for _, machineNetwork := range config.Networking.MachineNetwork {
+				s.MachineNetworks = append(s.MachineNetworks, machineNetwork.CIDR)
+			}
+		}
+	}
+	return nil
+}
+
+// node returns the node with the name, adding it when it is new. Nodes and
+// HostSubnets may be read in any order and more than once.
+func (s *ClusterState) node(name string) *ClusterNode {
+	for i := range s.Nodes {
+		if s.Nodes[i].Name == name {
+			return &s.Nodes[i]
+		}
+	}
+	s.Nodes = append(s.Nodes, ClusterNode{Name: name})
+	return &s.Nodes[len(s.Nodes)-1]
+}
+
+func (n *ClusterNode) merge(other ClusterNode) {
+	if len(other.InternalIPs) > 0 {
+		n.InternalIPs = other.InternalIPs
+	}
+	for _, subnet := range other.Subnets {
+		if !containsString(n.Subnets, subnet) {
+			n.Subnets = append(n.Subnets, subnet)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
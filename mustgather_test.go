@@ -0,0 +1,43 @@
+package onc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadMustGatherInstallConfig(t *testing.T) {
+	const configMapList = `apiVersion: v1
+kind: ConfigMapList
+items:
+- metadata:
+    name: other
+    namespace: kube-system
+  data:
+    key: value
+- metadata:
+    name: cluster-config-v1
+    namespace: kube-system
+  data:
+    install-config: |
+      networking:
+        machineNetwork:
+        - cidr: 10.0.0.0/16
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "quay-io-image", "namespaces", "kube-system", "core", "configmaps.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(configMapList), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := LoadMustGather(dir)
+	if err != nil {
+		t.Fatalf("LoadMustGather: %v", err)
+	}
+	if got := strings.Join(state.MachineNetworks, " "); got != "10.0.0.0/16" {
+		t.Errorf("machine networks = %q, want 10.0.0.0/16", got)
+	}
+}
@@ -2,9 +2,8 @@ package onc
 
 import (
 	"fmt"
-	"math"
+	"math/big"
 	"net"
-	"strconv"
 	"strings"
 )
 
@@ -14,105 +13,385 @@ type Request struct {
 	ServiceNetwork string `json:"serviceNetwork"`
 	Cni            string `json:"cni"`
 	MachineNetwork string `json:"machineNetwork"`
+
+	// OpenShiftVersion such as "4.16" selects the release rules; empty
+	// means the newest release.
+	OpenShiftVersion string `json:"openshiftVersion,omitempty"`
+
+	// Dual-stack clusters list one network per IP family, mirroring the
+	// install-config networking lists. The first entry selects the primary
+	// family. When set, these take precedence over the single-stack fields.
+	ClusterNetworks []ClusterNetworkEntry `json:"clusterNetworks,omitempty"`
+	ServiceNetworks []string              `json:"serviceNetworks,omitempty"`
+	MachineNetworks []string              `json:"machineNetworks,omitempty"`
+
+	OVNKubernetesConfig *OVNKubernetesConfig `json:"ovnKubernetesConfig,omitempty"`
+	CalicoConfig        *CalicoConfig        `json:"calicoConfig,omitempty"`
+	CiliumConfig        *CiliumConfig        `json:"ciliumConfig,omitempty"`
+
+	// Exclusions are external networks the cluster networks must not
+	// overlap.
+	Exclusions []Exclusion `json:"exclusions,omitempty"`
 }
 
+type ClusterNetworkEntry struct {
+	CIDR       string `json:"cidr" yaml:"cidr"`
+	HostPrefix int    `json:"hostPrefix" yaml:"hostPrefix"`
+}
+
+// Response carries the primary family at the top level so single-stack
+// clients keep working; Families holds one result per IP family. Conflicts
+// and Warnings list those of every family.
 type Response struct {
-	PodNetwork     string   `json:"pod-network"`
-	ServiceNetwork string   `json:"service-network"`
-	MachineNetwork string   `json:"machine-network"`
-	Cni            string   `json:"cni"`
-	NumPods        int      `json:"number-of-pods"`
-	NumServices    int      `json:"number-of-services"`
-	NumNodes       NumNodes `json:"number-of-nodes"`
-	PodsPerNode    int      `json:"pods-per-node"`
-	Conflicts      bool     `json:"network-conflict"`
+	FamilyResult
+	Cni              string         `json:"cni"`
+	OpenShiftVersion string         `json:"openshift-version,omitempty"`
+	DualStack        bool           `json:"dual-stack"`
+	Conflicts        []Conflict     `json:"conflicts"`
+	Warnings         []Finding      `json:"warnings"`
+	Families         []FamilyResult `json:"families"`
+}
+
+// FamilyResult totals the capacity of every cluster network entry of a
+// family. PodsPerNode is the smallest value across the entries, since a node
+// may be given a subnet from any of them. For CNIs that allocate a subnet
+// per namespace, NumNamespaces counts those subnets instead of NumNodes.
+type FamilyResult struct {
+	Family          IPFamily               `json:"family"`
+	PodNetwork      string                 `json:"pod-network"`
+	ServiceNetwork  string                 `json:"service-network"`
+	MachineNetwork  string                 `json:"machine-network"`
+	NumPods         *big.Int               `json:"number-of-pods"`
+	NumServices     *big.Int               `json:"number-of-services"`
+	NumNodes        NumNodes               `json:"number-of-nodes"`
+	NumNamespaces   *big.Int               `json:"number-of-namespaces,omitempty"`
+	PodsPerNode     *big.Int               `json:"pods-per-node"`
+	Conflicts       []Conflict             `json:"conflicts"`
+	Warnings        []Finding              `json:"warnings"`
+	ClusterNetworks []ClusterNetworkResult `json:"cluster-networks"`
+	InternalSubnets []NamedNetwork         `json:"internal-subnets,omitempty"`
+	Addresses       AddressAccounting      `json:"addresses"`
+	ServiceIPs      []ServiceIP            `json:"service-ips"`
+}
+
+// ClusterNetworkResult is the capacity of one cluster network entry. For
+// CNIs that allocate blocks, NumNodes counts blocks, PodsPerBlock is the
+// capacity of one block and PodsPerNode the kubelet maxPods default, since
+// a node claims as many blocks as its pods need.
+type ClusterNetworkResult struct {
+	Name         string           `json:"name"`
+	CIDR         string           `json:"cidr"`
+	HostPrefix   int              `json:"host-prefix"`
+	NodePrefix   int              `json:"node-prefix"`
+	NumPods      *big.Int         `json:"number-of-pods"`
+	NumNodes     *big.Int         `json:"number-of-nodes"`
+	PodsPerNode  *big.Int         `json:"pods-per-node"`
+	PodsPerBlock *big.Int         `json:"pods-per-block,omitempty"`
+	NodeSubnet   AddressBreakdown `json:"node-subnet"`
 }
 
+// NumNodes values are arbitrary-precision because IPv6 networks easily
+// overflow int (e.g. a /48 split into /64 node subnets). Want is the number
+// of node subnets in the cluster network and Have the number of node IPs in
+// the machine network; Max is the smallest of all Limits, which also count
+// the OVN-Kubernetes join and transit switch subnets. When the cluster
+// network holds namespace subnets, only the machine network limits nodes
+// and Want is Have.
 type NumNodes struct {
-	Want int `json:"want"`
-	Have int `json:"have"`
+	Want      *big.Int    `json:"want"`
+	Have      *big.Int    `json:"have"`
+	Max       *big.Int    `json:"max"`
+	LimitedBy string      `json:"limited-by"`
+	Limits    []NodeLimit `json:"limits"`
 }
 
+type NodeLimit struct {
+	NamedNetwork
+	Nodes *big.Int `json:"nodes"`
+}
+
+// CalculateNetwork validates the whole request before calculating. All
+// field problems are returned together as a *ValidationError.
 func CalculateNetwork(request Request) (*Response, error) {
-	networks := []string{request.ClusterNetwork, request.ServiceNetwork}
-	for _, network := range networks {
-		if !isValidCIDR(network) {
-			return nil, fmt.Errorf("Invalid network CIDR: %s", network)
+	var v validation
+	release, err := LookupRelease(request.OpenShiftVersion)
+	if err := v.add(err); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		release, _ = LookupRelease("")
+	}
+	// An unknown CNI still gets the networks checked, with the defaults of
+	// the default CNI.
+	cni, err := LookupCNI(request.Cni)
+	if err := v.add(err); err != nil {
+		return nil, err
+	}
+	if cni != nil {
+		if err := v.add(cni.Supported(release)); err != nil {
+			return nil, err
 		}
+		request = applyDefaults(request, cni)
+	} else {
+		defaultCNI, _ := LookupCNI("")
+		request = applyDefaults(request, defaultCNI)
 	}
+	profileErrs := len(v.errs)
 
-	podNetwork := request.ClusterNetwork
-	serviceNetwork := request.ServiceNetwork
-	machineNetwork := request.MachineNetwork
-	hostPrefix := request.HostPrefix
-	cni := request.Cni
+	clusterNetworks := request.clusterNetworks()
+	serviceNetworks := request.serviceNetworks()
+	machineNetworks := request.machineNetworks()
 
-	numPods, err := countIPs(podNetwork)
+	for i, clusterNetwork := range clusterNetworks {
+		if !isValidCIDR(clusterNetwork.CIDR) {
+			v.errs = append(v.errs, invalidCIDR(fmt.Sprintf("clusterNetwork[%d].cidr", i), clusterNetwork.CIDR))
+		}
+	}
+	for i, network := range serviceNetworks {
+		if !isValidCIDR(network) {
+			v.errs = append(v.errs, invalidCIDR(fmt.Sprintf("serviceNetwork[%d]", i), network))
+		}
+	}
+	for i, network := range machineNetworks {
+		if !isValidCIDR(network) {
+			v.errs = append(v.errs, invalidCIDR(fmt.Sprintf("machineNetwork[%d]", i), network))
+		}
+	}
+	if err := v.add(validateExclusions(request.Exclusions)); err != nil {
+		return nil, err
+	}
+	// The families of invalid CIDRs are unknown, so the checks that follow
+	// need every CIDR to parse.
+	if len(v.errs) > profileErrs {
+		return nil, v.err()
+	}
+
+	families, grouped, err := groupClusterNetworks(clusterNetworks)
 	if err != nil {
 		return nil, err
 	}
-	numNodes := len(splitSubnet(podNetwork, hostPrefix))
-	var totalPodsPerNode int
-	if numNodes != 0 {
-		totalPodsPerNode = numPods / numNodes
-	} else {
-		return nil, fmt.Errorf("numNodes is 0")
+	if err := v.add(validateDualStack(families, serviceNetworks, machineNetworks)); err != nil {
+		return nil, err
+	}
+	if cni != nil {
+		for _, family := range families {
+			if err := checkFamily(cni, family); err != nil {
+				v.errs = append(v.errs, err)
+			}
+		}
+	}
+	// The per-family calculation needs a CNI and one service and machine
+	// network per family; its own errors are added to the ones so far.
+	if cni == nil || len(v.errs) > profileErrs {
+		return nil, v.err()
 	}
 
-	var podsPerNode int
-	if cni == "ovn-kubernetes" {
-		podsPerNode = totalPodsPerNode - 3
-	} else if cni == "openshift-sdn" {
-		podsPerNode = totalPodsPerNode - 2
+	response := &Response{
+		Cni:              cni.Name(),
+		OpenShiftVersion: release.Version,
+		DualStack:        len(families) > 1,
+		Conflicts:        []Conflict{},
+		Warnings:         []Finding{},
 	}
+	for i, family := range families {
+		var familyClusterNetworks []NamedNetwork
+		var hostPrefixes []int
+		for _, j := range grouped[family] {
+			familyClusterNetworks = append(familyClusterNetworks, NamedNetwork{
+				Name: fmt.Sprintf("clusterNetwork[%d]", j),
+				Role: RoleCluster,
+				CIDR: clusterNetworks[j].CIDR,
+			})
+			hostPrefixes = append(hostPrefixes, clusterNetworks[j].HostPrefix)
+		}
+		serviceNetwork := NamedNetwork{Name: fmt.Sprintf("serviceNetwork[%d]", i), Role: RoleService, CIDR: serviceNetworks[i]}
+		machineNetwork := NamedNetwork{Name: fmt.Sprintf("machineNetwork[%d]", i), Role: RoleMachine, CIDR: machineNetworks[i]}
+		result, err := calculateFamily(family, familyClusterNetworks, hostPrefixes, serviceNetwork, machineNetwork, request, cni, release)
+		if err != nil {
+			if err := v.add(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		response.Families = append(response.Families, *result)
+		response.Conflicts = append(response.Conflicts, result.Conflicts...)
+		response.Warnings = append(response.Warnings, result.Warnings...)
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	response.FamilyResult = response.Families[0]
 
-	numServices, err := countIPs(serviceNetwork)
-	if err != nil {
+	return response, nil
+}
+
+func calculateFamily(family IPFamily, clusterNetworks []NamedNetwork, hostPrefixes []int, service, machine NamedNetwork, request Request, cni CNI, release Release) (*FamilyResult, error) {
+	serviceNetwork := service.CIDR
+	machineNetwork := machine.CIDR
+
+	var podNetworks []string
+	var clusterNetworkResults []ClusterNetworkResult
+	numPods := new(big.Int)
+	numNodes := new(big.Int)
+	podTotal := new(big.Int)
+	var podReserved []Reservation
+	var podsPerNode *big.Int
+	var v validation
+	for i, clusterNetwork := range clusterNetworks {
+		result, err := calculateClusterNetwork(clusterNetwork.Name, ClusterNetworkEntry{CIDR: clusterNetwork.CIDR, HostPrefix: hostPrefixes[i]}, family, request, cni)
+		if err != nil {
+			if err := v.add(err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		podNetworks = append(podNetworks, clusterNetwork.CIDR)
+		clusterNetworkResults = append(clusterNetworkResults, *result)
+		numPods.Add(numPods, result.NumPods)
+		numNodes.Add(numNodes, result.NumNodes)
+		podTotal.Add(podTotal, new(big.Int).Mul(result.NodeSubnet.Total, result.NumNodes))
+		podReserved = addReservations(podReserved, result.NodeSubnet.Reserved, result.NumNodes)
+		if podsPerNode == nil || result.PodsPerNode.Cmp(podsPerNode) < 0 {
+			podsPerNode = result.PodsPerNode
+		}
+	}
+	serviceIPs, err := wellKnownServiceIPs(service)
+	if err := v.add(err); err != nil {
 		return nil, err
 	}
+	internalSubnets, err := cni.InternalSubnets(family, request, release)
+	if err := v.add(err); err != nil {
+		return nil, err
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	podNetwork := strings.Join(podNetworks, ",")
 
-	machineNetworkNodes, err := countIPs(machineNetwork)
+	_, serviceIPNet, err := net.ParseCIDR(serviceNetwork)
 	if err != nil {
 		return nil, err
 	}
+	_, machineIPNet, err := net.ParseCIDR(machineNetwork)
+	if err != nil {
+		return nil, err
+	}
+	addresses := AddressAccounting{
+		Pod:     newAddressBreakdown(podTotal, podReserved),
+		Service: serviceAddresses(family, serviceIPNet),
+		Machine: machineAddresses(family, machineIPNet),
+	}
+	numServices := addresses.Service.Allocatable
+	machineNetworkNodes := addresses.Machine.Allocatable
 
+	var numNamespaces *big.Int
+	limits := []NodeLimit{{NamedNetwork: machine, Nodes: machineNetworkNodes}}
+	if allocatesPerNamespace(cni) {
+		numNamespaces, numNodes = numNodes, machineNetworkNodes
+	} else {
+		limits = append([]NodeLimit{{NamedNetwork: NamedNetwork{Name: "clusterNetwork", Role: RoleCluster, CIDR: podNetwork}, Nodes: numNodes}}, limits...)
+	}
+
+	for _, subnet := range internalSubnets {
+		if subnet.Role == RoleJoin || subnet.Role == RoleTransit {
+			_, ipNet, _ := net.ParseCIDR(subnet.CIDR)
+			capacity := ovnSwitchCapacity(ipNet)
+			limits = append(limits, NodeLimit{NamedNetwork: subnet, Nodes: capacity})
+			// The release defaults only cap the node count, but a custom
+			// subnet that cannot hold every node subnet is a mistake.
+			if capacity.Cmp(numNodes) < 0 && !isDefaultOVNSubnet(release, family, subnet) {
+				v.errs = append(v.errs, fieldError(subnet.Name, ErrTooSmall, subnet.CIDR, "%s %s holds %s nodes, fewer than the %s node subnets of the cluster network", subnet.Name, subnet.CIDR, capacity, numNodes))
+			}
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
 	clusterNumNodes := NumNodes{
-		Want: numNodes,
-		Have: machineNetworkNodes,
+		Want:   numNodes,
+		Have:   machineNetworkNodes,
+		Limits: limits,
+	}
+	for _, limit := range limits {
+		if clusterNumNodes.Max == nil || limit.Nodes.Cmp(clusterNumNodes.Max) < 0 {
+			clusterNumNodes.Max = limit.Nodes
+			clusterNumNodes.LimitedBy = limit.Name
+		}
 	}
 
-	sdnConflicts, err := checkCIDRConflict(request.ClusterNetwork, request.ServiceNetwork, request.MachineNetwork)
+	networks := append(clusterNetworks, service, machine)
+	networks = append(networks, internalSubnets...)
+	networks = append(networks, exclusionNetworks(family, request.Exclusions)...)
+	conflicts, err := checkCIDRConflict(networks...)
 	if err != nil {
-		fmt.Println("Error:", err)
 		return nil, err
 	}
 
-	joinSwitch := "100.64.0.0/16"
-	trasitSwitch := "100.88.0.0/16"
+	result := &FamilyResult{
+		Family:          family,
+		PodNetwork:      podNetwork,
+		ServiceNetwork:  serviceNetwork,
+		MachineNetwork:  machineNetwork,
+		NumPods:         numPods,
+		NumServices:     numServices,
+		NumNodes:        clusterNumNodes,
+		NumNamespaces:   numNamespaces,
+		PodsPerNode:     podsPerNode,
+		Conflicts:       conflicts,
+		ClusterNetworks: clusterNetworkResults,
+		InternalSubnets: internalSubnets,
+		Addresses:       addresses,
+		ServiceIPs:      serviceIPs,
+	}
+	result.Warnings, err = checkFindings(result, clusterNetworks, service, machine, cni)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func calculateClusterNetwork(name string, clusterNetwork ClusterNetworkEntry, family IPFamily, request Request, cni CNI) (*ClusterNetworkResult, error) {
+	nodePrefix, err := cni.NodePrefix(family, clusterNetwork.HostPrefix, request)
+	if err != nil {
+		return nil, err
+	}
+	reservations := cni.NodeReservations(family, request)
+	if err := validateHostPrefix(name, clusterNetwork, family, nodePrefix, reservations, request, cni); err != nil {
+		return nil, err
+	}
 
-	ovnConflicts, err := checkCIDRConflict(request.ClusterNetwork, request.ServiceNetwork, request.MachineNetwork, joinSwitch, trasitSwitch)
+	_, ipNet, err := net.ParseCIDR(clusterNetwork.CIDR)
+	if err != nil {
+		return nil, err
+	}
+	numNodes, err := countSubnets(clusterNetwork.CIDR, nodePrefix)
 	if err != nil {
-		fmt.Println("Error:", err)
 		return nil, err
 	}
 
-	var conflicts bool
-	if cni == "ovn-kubernetes" {
-		conflicts = ovnConflicts
-	} else if cni == "openshift-sdn" {
-		conflicts = sdnConflicts
+	_, bits := ipNet.Mask.Size()
+	nodeTotal := new(big.Int).Lsh(big.NewInt(1), uint(bits-nodePrefix))
+	nodeSubnet := newAddressBreakdown(nodeTotal, reservations)
+	podsPerNode := nodeSubnet.Allocatable
+	numPods := new(big.Int).Mul(podsPerNode, numNodes)
+	var podsPerBlock *big.Int
+	if allocatesBlocks(cni) {
+		podsPerBlock, podsPerNode = podsPerNode, big.NewInt(minPodsPerNode)
+		if numPods.Cmp(podsPerNode) < 0 {
+			podsPerNode = numPods
+		}
 	}
 
-	return &Response{
-		PodNetwork:     podNetwork,
-		ServiceNetwork: serviceNetwork,
-		MachineNetwork: machineNetwork,
-		NumPods:        numPods,
-		NumServices:    numServices,
-		NumNodes:       clusterNumNodes,
-		PodsPerNode:    podsPerNode,
-		Conflicts:      conflicts,
-		Cni:            cni,
+	return &ClusterNetworkResult{
+		Name:         name,
+		CIDR:         clusterNetwork.CIDR,
+		HostPrefix:   clusterNetwork.HostPrefix,
+		NodePrefix:   nodePrefix,
+		NumPods:      numPods,
+		NumNodes:     numNodes,
+		PodsPerNode:  podsPerNode,
+		PodsPerBlock: podsPerBlock,
+		NodeSubnet:   nodeSubnet,
 	}, nil
 }
 
@@ -121,69 +400,67 @@ func isValidCIDR(cidr string) bool {
 	return err == nil
 }
 
-func countIPs(cidr string) (int, error) {
+func countIPs(cidr string) (*big.Int, error) {
 	_, ipNet, err := net.ParseCIDR(cidr)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	networkPrefix, err := strconv.Atoi(strings.Split(ipNet.String(), "/")[1])
-	if err != nil {
-		return 0, err
+	ones, bits := ipNet.Mask.Size()
+	IPs := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	// IPv4 loses the network and broadcast addresses; IPv6 has no
+	// broadcast but the first address is the subnet-router anycast.
+	if bits == 8*net.IPv4len {
+		IPs.Sub(IPs, big.NewInt(2))
+	} else {
+		IPs.Sub(IPs, big.NewInt(1))
+	}
+	if IPs.Sign() < 0 {
+		IPs.SetInt64(0)
 	}
-	IPs := int(float64(math.Pow(2, float64(32-networkPrefix)) - 2))
 	return IPs, nil
 }
 
-func splitSubnet(subnet string, prefixLength int) []*net.IPNet {
-	var subnets []*net.IPNet
-
+// countSubnets returns how many /prefixLength subnets fit in subnet without
+// enumerating them, which is not feasible for most IPv6 networks.
+func countSubnets(subnet string, prefixLength int) (*big.Int, error) {
 	_, snet, err := net.ParseCIDR(subnet)
 	if err != nil {
-		fmt.Println("Error parsing original subnet:", err)
-		return subnets
-	}
-	ip := snet.IP
-	ones, _ := snet.Mask.Size()
-	subnetCount := 2 << uint(prefixLength-ones-1)
-	stepSize := 1 << uint(32-ones)
-
-	for i := 0; i < subnetCount; i++ {
-		newIP := make(net.IP, len(ip))
-		copy(newIP, ip)
-		for j := 3; j >= 0; j-- {
-			newIP[j] += byte((i * stepSize) >> uint((3-j)*8))
-		}
-
-		newSubnet := &net.IPNet{
-			IP:   newIP,
-			Mask: net.CIDRMask(prefixLength, 32),
-		}
-
-		subnets = append(subnets, newSubnet)
+		return nil, err
 	}
-
-	return subnets
+	ones, bits := snet.Mask.Size()
+	if prefixLength < ones || prefixLength > bits {
+		return new(big.Int), nil
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(prefixLength-ones)), nil
 }
 
-func checkCIDRConflict(cidrs ...string) (bool, error) {
-	var networks []*net.IPNet
-
-	for _, cidr := range cidrs {
-		_, netIP, err := net.ParseCIDR(cidr)
-		if err != nil {
-			return false, err
-		}
-		networks = append(networks, netIP)
+// nthSubnet returns the n-th /prefixLength subnet of network, counting from 0.
+func nthSubnet(network *net.IPNet, prefixLength int, n *big.Int) *net.IPNet {
+	_, bits := network.Mask.Size()
+	offset := new(big.Int).Lsh(n, uint(bits-prefixLength))
+	return &net.IPNet{
+		IP:   addIP(network.IP, offset),
+		Mask: net.CIDRMask(prefixLength, bits),
 	}
+}
 
-	// Check for conflicts
-	for i, network1 := range networks {
-		for j, network2 := range networks {
-			if i != j && (network1.Contains(network2.IP) || network2.Contains(network1.IP)) {
-				return true, nil
-			}
-		}
+func ipToInt(ip net.IP) *big.Int {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
 	}
+	return new(big.Int).SetBytes(ip)
+}
 
-	return false, nil
+func intToIP(n *big.Int, size int) net.IP {
+	ip := make(net.IP, size)
+	n.FillBytes(ip)
+	return ip
+}
+
+func addIP(ip net.IP, offset *big.Int) net.IP {
+	size := len(ip)
+	if v4 := ip.To4(); v4 != nil {
+		size = net.IPv4len
+	}
+	return intToIP(new(big.Int).Add(ipToInt(ip), offset), size)
 }
@@ -0,0 +1,215 @@
+package onc
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestCalculateNetwork(t *testing.T) {
+	type family struct {
+		family      IPFamily
+		podsPerNode string
+		nodes       string
+		maxNodes    string
+		pods        string
+		services    string
+	}
+	ipv4 := family{IPv4, "507", "512", "512", "259584", "65532"}
+	ipv6 := family{IPv6, "18446744073709551613", "65536", "65536", "1208925819614629174509568", "65533"}
+
+	tests := []struct {
+		name     string
+		request  Request
+		families []family
+	}{
+		{
+			name:     "IPv4 defaults",
+			request:  Request{MachineNetwork: "10.0.0.0/16"},
+			families: []family{ipv4},
+		},
+		{
+			name: "IPv6",
+			request: Request{
+				ClusterNetwork: "fd01::/48",
+				HostPrefix:     64,
+				ServiceNetwork: "fd02::/112",
+				MachineNetwork: "fd00::/64",
+			},
+			families: []family{ipv6},
+		},
+		{
+			name: "dual-stack",
+			request: Request{
+				ClusterNetworks: []ClusterNetworkEntry{{CIDR: "10.128.0.0/14", HostPrefix: 23}, {CIDR: "fd01::/48", HostPrefix: 64}},
+				ServiceNetworks: []string{"172.30.0.0/16", "fd02::/112"},
+				MachineNetworks: []string{"10.0.0.0/16", "fd00::/64"},
+			},
+			families: []family{ipv4, ipv6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := CalculateNetwork(tt.request)
+			if err != nil {
+				t.Fatalf("CalculateNetwork: %v", err)
+			}
+			if got, want := response.DualStack, len(tt.families) > 1; got != want {
+				t.Errorf("dual-stack = %v, want %v", got, want)
+			}
+			if len(response.Families) != len(tt.families) {
+				t.Fatalf("got %d families, want %d", len(response.Families), len(tt.families))
+			}
+			if len(response.Conflicts) != 0 {
+				t.Errorf("unexpected conflicts: %v", response.Conflicts)
+			}
+			for i, want := range tt.families {
+				got := response.Families[i]
+				if got.Family != want.family {
+					t.Errorf("families[%d] = %s, want %s", i, got.Family, want.family)
+				}
+				for _, count := range []struct {
+					name string
+					got  *big.Int
+					want string
+				}{
+					{"pods-per-node", got.PodsPerNode, want.podsPerNode},
+					{"number-of-nodes.want", got.NumNodes.Want, want.nodes},
+					{"number-of-nodes.max", got.NumNodes.Max, want.maxNodes},
+					{"number-of-pods", got.NumPods, want.pods},
+					{"number-of-services", got.NumServices, want.services},
+				} {
+					if count.got.String() != count.want {
+						t.Errorf("%s %s = %s, want %s", want.family, count.name, count.got, count.want)
+					}
+				}
+			}
+			if response.PodsPerNode.Cmp(response.Families[0].PodsPerNode) != 0 {
+				t.Errorf("top-level pods-per-node %s is not the primary family's %s", response.PodsPerNode, response.Families[0].PodsPerNode)
+			}
+		})
+	}
+}
+
+func TestCalculateNetworkNodePrefixErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		field   string
+	}{
+		{
+			name:    "cilium IPv4 mask longer than the address",
+			request: Request{Cni: "cilium", MachineNetwork: "10.0.0.0/16", CiliumConfig: &CiliumConfig{ClusterPoolIPv4MaskSize: 33}},
+			field:   "ciliumConfig.clusterPoolIPv4MaskSize",
+		},
+		{
+			name:    "cilium IPv4 mask without room for pods",
+			request: Request{Cni: "cilium", MachineNetwork: "10.0.0.0/16", CiliumConfig: &CiliumConfig{ClusterPoolIPv4MaskSize: 31}},
+			field:   "ciliumConfig.clusterPoolIPv4MaskSize",
+		},
+		{
+			name: "cilium IPv6 mask longer than the address",
+			request: Request{
+				Cni:            "cilium",
+				ClusterNetwork: "fd01::/48",
+				HostPrefix:     64,
+				ServiceNetwork: "fd02::/112",
+				MachineNetwork: "fd00::/64",
+				CiliumConfig:   &CiliumConfig{ClusterPoolIPv6MaskSize: 200},
+			},
+			field: "ciliumConfig.clusterPoolIPv6MaskSize",
+		},
+		{
+			name:    "calico block without room for the tunnel address",
+			request: Request{Cni: "calico", MachineNetwork: "10.0.0.0/16", CalicoConfig: &CalicoConfig{IPv4BlockSize: 32}},
+			field:   "calicoConfig.ipv4BlockSize",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CalculateNetwork(tt.request)
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("CalculateNetwork error = %v, want a field error", err)
+			}
+			if fieldErr.Field != tt.field || fieldErr.Code != ErrOutOfRange {
+				t.Errorf("got %s %s, want %s %s", fieldErr.Field, fieldErr.Code, tt.field, ErrOutOfRange)
+			}
+		})
+	}
+}
+
+func TestCalculateNetworkReportsAllErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		fields  []string
+	}{
+		{
+			name:    "unknown CNI and invalid CIDRs",
+			request: Request{Cni: "bogus", ClusterNetwork: "x", ServiceNetwork: "y", MachineNetwork: "10.0.0.0/16"},
+			fields:  []string{"cni", "clusterNetwork[0].cidr", "serviceNetwork[0]"},
+		},
+		{
+			name:    "hostPrefix and service network",
+			request: Request{HostPrefix: 10, ServiceNetwork: "172.30.0.0/30", MachineNetwork: "10.0.0.0/16"},
+			fields:  []string{"clusterNetwork[0].hostPrefix", "serviceNetwork[0]"},
+		},
+		{
+			name: "service and machine network families",
+			request: Request{
+				ClusterNetworks: []ClusterNetworkEntry{{CIDR: "10.128.0.0/14", HostPrefix: 23}, {CIDR: "fd01::/48", HostPrefix: 64}},
+				ServiceNetworks: []string{"172.30.0.0/16"},
+				MachineNetworks: []string{"fd00::/64", "10.0.0.0/16"},
+			},
+			fields: []string{"serviceNetwork", "machineNetwork"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CalculateNetwork(tt.request)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("CalculateNetwork error = %v, want a validation error", err)
+			}
+			var fields []string
+			for _, fieldErr := range validationErr.Errors {
+				fields = append(fields, fieldErr.Field)
+			}
+			if strings.Join(fields, " ") != strings.Join(tt.fields, " ") {
+				t.Errorf("error fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestCalculateNetworkKuryrNamespaces(t *testing.T) {
+	response, err := CalculateNetwork(Request{Cni: "kuryr", OpenShiftVersion: "4.14", MachineNetwork: "10.0.0.0/24"})
+	if err != nil {
+		t.Fatalf("CalculateNetwork: %v", err)
+	}
+	if got := response.NumNamespaces.String(); got != "512" {
+		t.Errorf("number-of-namespaces = %s, want 512", got)
+	}
+	numNodes := response.NumNodes
+	if numNodes.Want.String() != "253" || numNodes.Max.String() != "253" || numNodes.LimitedBy != "machineNetwork[0]" {
+		t.Errorf("number-of-nodes = %s want, %s max limited by %s, want the 253 machine network nodes", numNodes.Want, numNodes.Max, numNodes.LimitedBy)
+	}
+	if HasFinding(response.Warnings, CodeSmallMachineNetwork) {
+		t.Errorf("unexpected %s warning: %v", CodeSmallMachineNetwork, response.Warnings)
+	}
+}
+
+func TestCalculateNetworkCalicoPodsPerNode(t *testing.T) {
+	response, err := CalculateNetwork(Request{Cni: "calico", MachineNetwork: "10.0.0.0/16"})
+	if err != nil {
+		t.Fatalf("CalculateNetwork: %v", err)
+	}
+	if got := response.PodsPerNode.String(); got != "250" {
+		t.Errorf("pods-per-node = %s, want the kubelet maxPods default 250", got)
+	}
+	if got := response.ClusterNetworks[0].PodsPerBlock.String(); got != "63" {
+		t.Errorf("pods-per-block = %s, want 63 in a /26 block", got)
+	}
+}
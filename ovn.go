@@ -0,0 +1,245 @@
+package onc
+
+import (
+	"math/big"
+	"net"
+)
+
+// OVNKubernetesConfig mirrors the internal subnet settings of
+// spec.defaultNetwork.ovnKubernetesConfig in the Cluster Network Operator
+// configuration. Empty fields fall back to the OVN-Kubernetes defaults.
+type OVNKubernetesConfig struct {
+	V4InternalSubnet string         `json:"v4InternalSubnet,omitempty" yaml:"v4InternalSubnet,omitempty"`
+	V6InternalSubnet string         `json:"v6InternalSubnet,omitempty" yaml:"v6InternalSubnet,omitempty"`
+	IPv4             *OVNIPConfig   `json:"ipv4,omitempty" yaml:"ipv4,omitempty"`
+	IPv6             *OVNIPConfig   `json:"ipv6,omitempty" yaml:"ipv6,omitempty"`
+	GatewayConfig    *GatewayConfig `json:"gatewayConfig,omitempty" yaml:"gatewayConfig,omitempty"`
+}
+
+type OVNIPConfig struct {
+	InternalJoinSubnet          string `json:"internalJoinSubnet,omitempty" yaml:"internalJoinSubnet,omitempty"`
+	InternalTransitSwitchSubnet string `json:"internalTransitSwitchSubnet,omitempty" yaml:"internalTransitSwitchSubnet,omitempty"`
+}
+
+type GatewayConfig struct {
+	IPv4 *GatewayIPConfig `json:"ipv4,omitempty" yaml:"ipv4,omitempty"`
+	IPv6 *GatewayIPConfig `json:"ipv6,omitempty" yaml:"ipv6,omitempty"`
+}
+
+type GatewayIPConfig struct {
+	InternalMasqueradeSubnet string `json:"internalMasqueradeSubnet,omitempty" yaml:"internalMasqueradeSubnet,omitempty"`
+}
+
+type ovnKubernetes struct{}
+
+func init() {
+	RegisterCNI(ovnKubernetes{})
+}
+
+func (ovnKubernetes) Name() string {
+	return "ovn-kubernetes"
+}
+
+func (ovnKubernetes) SupportedFamilies() []IPFamily {
+	return []IPFamily{IPv4, IPv6}
+}
+
+func (ovnKubernetes) Defaults() CNIDefaults {
+	return openshiftDefaults
+}
+
+func (ovnKubernetes) Supported(release Release) error {
+	return nil
+}
+
+func (ovnKubernetes) NodePrefix(family IPFamily, hostPrefix int, request Request) (int, error) {
+	return hostPrefix, nil
+}
+
+// NodeReservations adds the gateway router port, the management port and,
+// for IPv4, the hybrid overlay address to the subnet edges.
+func (ovnKubernetes) NodeReservations(family IPFamily, request Request) []Reservation {
+	reserved := append(subnetEdges(family), reserveAt(AddressGateway, "OVN gateway router port", 1), reserveAt(AddressManagementPort, "OVN management port", 2))
+	if family == IPv4 {
+		reserved = append(reserved, reserveAt(AddressHybridOverlay, "hybrid overlay", 3))
+	}
+	return reserved
+}
+
+func (ovnKubernetes) InternalSubnets(family IPFamily, request Request, release Release) ([]NamedNetwork, error) {
+	subnets, err := ovnInternalSubnets(family, request.OVNKubernetesConfig, release)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateOVNInternalSubnets(family, subnets); err != nil {
+		return nil, err
+	}
+	return subnets, nil
+}
+
+// minMasqueradePrefix is the longest prefix that still holds the six
+// masquerade addresses OVN-Kubernetes configures on every node.
+var minMasqueradePrefix = map[IPFamily]int{
+	IPv4: 29,
+	IPv6: 125,
+}
+
+// ovnInternalSubnets returns the join, transit switch and masquerade subnets
+// of a family, preferring the user overrides over the release defaults. The
+// newer ipv4/ipv6.internalJoinSubnet fields win over v4/v6InternalSubnet.
+// Releases without OVN interconnect have no transit switch subnet.
+func ovnInternalSubnets(family IPFamily, config *OVNKubernetesConfig, release Release) ([]NamedNetwork, error) {
+	subnets := release.OVNSubnets[family]
+	joinField, transitField, masqueradeField := ovnFieldNames(family)
+	if config != nil {
+		internalSubnet, ipConfig, gatewayConfig := config.V4InternalSubnet, config.IPv4, (*GatewayIPConfig)(nil)
+		if config.GatewayConfig != nil {
+			gatewayConfig = config.GatewayConfig.IPv4
+		}
+		if family == IPv6 {
+			internalSubnet, ipConfig = config.V6InternalSubnet, config.IPv6
+			if config.GatewayConfig != nil {
+				gatewayConfig = config.GatewayConfig.IPv6
+			}
+		}
+		if internalSubnet != "" {
+			subnets.Join = internalSubnet
+		}
+		if ipConfig != nil && ipConfig.InternalJoinSubnet != "" {
+			subnets.Join = ipConfig.InternalJoinSubnet
+			joinField = "ovnKubernetesConfig." + ipFamilyField(family) + ".internalJoinSubnet"
+		}
+		if ipConfig != nil && ipConfig.InternalTransitSwitchSubnet != "" {
+			if !release.TransitSwitch {
+				return nil, fieldError(transitField, ErrUnsupported, ipConfig.InternalTransitSwitchSubnet, "%s requires OpenShift 4.%d or later", transitField, transitSwitchSince)
+			}
+			subnets.Transit = ipConfig.InternalTransitSwitchSubnet
+		}
+		if gatewayConfig != nil && gatewayConfig.InternalMasqueradeSubnet != "" {
+			subnets.Masquerade = gatewayConfig.InternalMasqueradeSubnet
+		}
+	}
+	networks := []NamedNetwork{{Name: joinField, Role: RoleJoin, CIDR: subnets.Join}}
+	if release.TransitSwitch {
+		networks = append(networks, NamedNetwork{Name: transitField, Role: RoleTransit, CIDR: subnets.Transit})
+	}
+	networks = append(networks, NamedNetwork{Name: masqueradeField, Role: RoleMasquerade, CIDR: subnets.Masquerade})
+	return networks, nil
+}
+
+func ovnFieldNames(family IPFamily) (join, transit, masquerade string) {
+	field := ipFamilyField(family)
+	join = "ovnKubernetesConfig.v4InternalSubnet"
+	if family == IPv6 {
+		join = "ovnKubernetesConfig.v6InternalSubnet"
+	}
+	return join, "ovnKubernetesConfig." + field + ".internalTransitSwitchSubnet", "ovnKubernetesConfig.gatewayConfig." + field + ".internalMasqueradeSubnet"
+}
+
+func ipFamilyField(family IPFamily) string {
+	if family == IPv6 {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// validateOVNInternalSubnets checks that the internal subnets belong to the
+// family and that the masquerade subnet holds its six addresses. Small join
+// and transit switch subnets are not an error; they cap the node count.
+func validateOVNInternalSubnets(family IPFamily, subnets []NamedNetwork) error {
+	var v validation
+	for _, subnet := range subnets {
+		got, err := cidrFamily(subnet.CIDR)
+		if err != nil {
+			v.errs = append(v.errs, invalidCIDR(subnet.Name, subnet.CIDR))
+			continue
+		}
+		if got != family {
+			v.errs = append(v.errs, fieldError(subnet.Name, ErrFamilyMismatch, subnet.CIDR, "%s %s is not an %s network", subnet.Name, subnet.CIDR, family))
+			continue
+		}
+		_, ipNet, _ := net.ParseCIDR(subnet.CIDR)
+		ones, _ := ipNet.Mask.Size()
+		if subnet.Role == RoleMasquerade && ones > minMasqueradePrefix[family] {
+			v.errs = append(v.errs, fieldError(subnet.Name, ErrTooSmall, subnet.CIDR, "%s %s is too small; it needs at least a /%d", subnet.Name, subnet.CIDR, minMasqueradePrefix[family]))
+		}
+	}
+	return v.err()
+}
+
+// isDefaultOVNSubnet reports whether a join or transit switch subnet is the
+// default of the release rather than a user override.
+func isDefaultOVNSubnet(release Release, family IPFamily, subnet NamedNetwork) bool {
+	defaults := release.OVNSubnets[family]
+	switch subnet.Role {
+	case RoleJoin:
+		return subnet.CIDR == defaults.Join
+	case RoleTransit:
+		return subnet.CIDR == defaults.Transit
+	}
+	return false
+}
+
+// ovnSwitchCapacity returns how many nodes fit in a join or transit switch
+// subnet: the first address goes to the cluster router and IPv4 also loses
+// the network and broadcast addresses.
+func ovnSwitchCapacity(ipNet *net.IPNet) *big.Int {
+	capacity, _ := countIPs(ipNet.String())
+	capacity.Sub(capacity, big.NewInt(1))
+	if capacity.Sign() < 0 {
+		capacity.SetInt64(0)
+	}
+	return capacity
+}
+
+// ConfigureDefaultNetwork sets the internal subnets in the
+// ovnKubernetesConfig of the operator defaultNetwork.
+func (ovnKubernetes) ConfigureDefaultNetwork(network *DefaultNetwork, subnets []NamedNetwork) {
+	config := &OVNKubernetesConfig{}
+	for _, subnet := range subnets {
+		setOVNField(config, subnet.Name, subnet.CIDR)
+	}
+	network.OVNKubernetesConfig = config
+}
+
+// setOVNField sets the ovnKubernetesConfig field named by an internal
+// subnet, such as ovnKubernetesConfig.ipv4.internalTransitSwitchSubnet.
+func setOVNField(config *OVNKubernetesConfig, field, cidr string) {
+	ipConfig := func(c **OVNIPConfig) *OVNIPConfig {
+		if *c == nil {
+			*c = &OVNIPConfig{}
+		}
+		return *c
+	}
+	gatewayConfig := func(family IPFamily) *GatewayIPConfig {
+		if config.GatewayConfig == nil {
+			config.GatewayConfig = &GatewayConfig{}
+		}
+		c := &config.GatewayConfig.IPv4
+		if family == IPv6 {
+			c = &config.GatewayConfig.IPv6
+		}
+		if *c == nil {
+			*c = &GatewayIPConfig{}
+		}
+		return *c
+	}
+	switch field {
+	case "ovnKubernetesConfig.v4InternalSubnet":
+		config.V4InternalSubnet = cidr
+	case "ovnKubernetesConfig.v6InternalSubnet":
+		config.V6InternalSubnet = cidr
+	case "ovnKubernetesConfig.ipv4.internalJoinSubnet":
+		ipConfig(&config.IPv4).InternalJoinSubnet = cidr
+	case "ovnKubernetesConfig.ipv6.internalJoinSubnet":
+		ipConfig(&config.IPv6).InternalJoinSubnet = cidr
+	case "ovnKubernetesConfig.ipv4.internalTransitSwitchSubnet":
+		ipConfig(&config.IPv4).InternalTransitSwitchSubnet = cidr
+	case "ovnKubernetesConfig.ipv6.internalTransitSwitchSubnet":
+		ipConfig(&config.IPv6).InternalTransitSwitchSubnet = cidr
+	case "ovnKubernetesConfig.gatewayConfig.ipv4.internalMasqueradeSubnet":
+		gatewayConfig(IPv4).InternalMasqueradeSubnet = cidr
+	case "ovnKubernetesConfig.gatewayConfig.ipv6.internalMasqueradeSubnet":
+		gatewayConfig(IPv6).InternalMasqueradeSubnet = cidr
+	}
+}
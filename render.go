@@ -0,0 +1,118 @@
+package onc
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RenderedConfig holds install-time configuration for a request: the
+// networking stanza of install-config.yaml and, when the CNI internal
+// subnets differ from the release defaults, the
+// manifests/cluster-network-03-config.yml that sets them.
+type RenderedConfig struct {
+	Networking           []byte
+	ClusterNetworkConfig []byte
+}
+
+// ClusterNetworkConfigFile is the name the installer expects for the
+// Cluster Network Operator manifest.
+const ClusterNetworkConfigFile = "cluster-network-03-config.yml"
+
+// RenderInstallConfig validates a request and renders its install-time
+// configuration. Networks left empty get the CNI defaults, as in
+// CalculateNetwork.
+func RenderInstallConfig(request Request) (*RenderedConfig, error) {
+	response, err := CalculateNetwork(request)
+	if err != nil {
+		return nil, err
+	}
+	release, _ := LookupRelease(request.OpenShiftVersion)
+	cni, _ := LookupCNI(request.Cni)
+	request = applyDefaults(request, cni)
+
+	networking := InstallConfigNetworking{
+		NetworkType:    networkType(cni),
+		ClusterNetwork: request.clusterNetworks(),
+		ServiceNetwork: request.serviceNetworks(),
+	}
+	for _, cidr := range request.machineNetworks() {
+		networking.MachineNetwork = append(networking.MachineNetwork, InstallConfigMachineNetwork{CIDR: cidr})
+	}
+	rendered := &RenderedConfig{}
+	rendered.Networking, err = marshalYAML(struct {
+		Networking InstallConfigNetworking `yaml:"networking"`
+	}{networking})
+	if err != nil {
+		return nil, err
+	}
+
+	if configurer, ok := cni.(DefaultNetworkConfigurer); ok {
+		custom, err := customInternalSubnets(response, cni, release)
+		if err != nil {
+			return nil, err
+		}
+		if len(custom) > 0 {
+			config := newOperatorNetwork()
+			configurer.ConfigureDefaultNetwork(&config.Spec.DefaultNetwork, custom)
+			if rendered.ClusterNetworkConfig, err = marshalYAML(config); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rendered, nil
+}
+
+// customInternalSubnets returns the internal subnets of a calculation that
+// differ from the release defaults of the same role.
+func customInternalSubnets(response *Response, cni CNI, release Release) ([]NamedNetwork, error) {
+	var custom []NamedNetwork
+	for _, family := range response.Families {
+		defaults, err := cni.InternalSubnets(family.Family, Request{}, release)
+		if err != nil {
+			return nil, err
+		}
+		for _, subnet := range family.InternalSubnets {
+			for _, def := range defaults {
+				if def.Role == subnet.Role && def.CIDR != subnet.CIDR {
+					custom = append(custom, subnet)
+				}
+			}
+		}
+	}
+	return custom, nil
+}
+
+// YAML joins the rendered files into one multi-document stream, naming the
+// manifest file in a comment.
+func (r *RenderedConfig) YAML() []byte {
+	out := append([]byte(nil), r.Networking...)
+	if r.ClusterNetworkConfig != nil {
+		out = append(out, "---\n# manifests/"+ClusterNetworkConfigFile+"\n"...)
+		out = append(out, r.ClusterNetworkConfig...)
+	}
+	return out
+}
+
+// networkType returns the install-config networkType of a CNI profile.
+func networkType(cni CNI) string {
+	for networkType, name := range networkTypes {
+		if name == cni.Name() {
+			return networkType
+		}
+	}
+	return cni.Name()
+}
+
+func marshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
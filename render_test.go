@@ -0,0 +1,88 @@
+package onc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderInstallConfigClusterNetworkConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		want    string
+	}{
+		{
+			name:    "empty OVN-Kubernetes IPv4 settings",
+			request: Request{MachineNetwork: "10.0.0.0/16", OVNKubernetesConfig: &OVNKubernetesConfig{IPv4: &OVNIPConfig{}}},
+		},
+		{
+			name:    "join subnet at the release default",
+			request: Request{MachineNetwork: "10.0.0.0/16", OVNKubernetesConfig: &OVNKubernetesConfig{IPv4: &OVNIPConfig{InternalJoinSubnet: "100.64.0.0/16"}}},
+		},
+		{
+			name:    "custom join subnet",
+			request: Request{MachineNetwork: "10.0.0.0/16", OVNKubernetesConfig: &OVNKubernetesConfig{IPv4: &OVNIPConfig{InternalJoinSubnet: "100.66.0.0/16"}}},
+			want:    "internalJoinSubnet: 100.66.0.0/16",
+		},
+		{
+			name:    "other CNI",
+			request: Request{Cni: "openshift-sdn", OpenShiftVersion: "4.14", MachineNetwork: "10.0.0.0/16"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := RenderInstallConfig(tt.request)
+			if err != nil {
+				t.Fatalf("RenderInstallConfig: %v", err)
+			}
+			manifest := string(rendered.ClusterNetworkConfig)
+			if tt.want == "" {
+				if manifest != "" {
+					t.Errorf("unexpected %s:\n%s", ClusterNetworkConfigFile, manifest)
+				}
+				return
+			}
+			if !strings.Contains(manifest, tt.want) {
+				t.Errorf("%s does not set %q:\n%s", ClusterNetworkConfigFile, tt.want, manifest)
+			}
+			if strings.Contains(manifest, "internalTransitSwitchSubnet") {
+				t.Errorf("%s sets the default transit switch subnet:\n%s", ClusterNetworkConfigFile, manifest)
+			}
+		})
+	}
+}
+
+func TestRenderOperatorNetworkInternalSubnets(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		want    string
+	}{
+		{
+			name:    "release defaults",
+			request: Request{MachineNetwork: "10.0.0.0/16"},
+		},
+		{
+			name:    "custom masquerade subnet",
+			request: Request{MachineNetwork: "10.0.0.0/16", OVNKubernetesConfig: &OVNKubernetesConfig{GatewayConfig: &GatewayConfig{IPv4: &GatewayIPConfig{InternalMasqueradeSubnet: "169.254.64.0/18"}}}},
+			want:    "internalMasqueradeSubnet: 169.254.64.0/18",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := RenderOperatorNetwork(tt.request)
+			if err != nil {
+				t.Fatalf("RenderOperatorNetwork: %v", err)
+			}
+			if tt.want == "" {
+				if strings.Contains(string(manifest), "ovnKubernetesConfig") {
+					t.Errorf("manifest pins the release defaults:\n%s", manifest)
+				}
+				return
+			}
+			if !strings.Contains(string(manifest), tt.want) || strings.Contains(string(manifest), "internalJoinSubnet") {
+				t.Errorf("manifest does not set only %q:\n%s", tt.want, manifest)
+			}
+		})
+	}
+}
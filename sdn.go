@@ -0,0 +1,42 @@
+package onc
+
+type openshiftSDN struct{}
+
+func init() {
+	RegisterCNI(openshiftSDN{})
+}
+
+func (openshiftSDN) Name() string {
+	return "openshift-sdn"
+}
+
+func (openshiftSDN) SupportedFamilies() []IPFamily {
+	return []IPFamily{IPv4}
+}
+
+func (openshiftSDN) Defaults() CNIDefaults {
+	return openshiftDefaults
+}
+
+func (openshiftSDN) Supported(release Release) error {
+	if release.OpenShiftSDN {
+		return nil
+	}
+	if release.Version == "" {
+		return fieldError("cni", ErrUnsupported, "openshift-sdn", "openshift-sdn was removed in OpenShift 4.%d; use ovn-kubernetes or set an earlier openshiftVersion", sdnRemovedVersion)
+	}
+	return fieldError("cni", ErrUnsupported, "openshift-sdn", "openshift-sdn is not supported in OpenShift %s; it was removed in 4.%d, use ovn-kubernetes", release.Version, sdnRemovedVersion)
+}
+
+func (openshiftSDN) NodePrefix(family IPFamily, hostPrefix int, request Request) (int, error) {
+	return hostPrefix, nil
+}
+
+// NodeReservations adds the tun0 gateway to the subnet edges.
+func (openshiftSDN) NodeReservations(family IPFamily, request Request) []Reservation {
+	return append(subnetEdges(family), reserveAt(AddressGateway, "tun0 gateway", 1))
+}
+
+func (openshiftSDN) InternalSubnets(family IPFamily, request Request, release Release) ([]NamedNetwork, error) {
+	return nil, nil
+}
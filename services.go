@@ -0,0 +1,40 @@
+package onc
+
+import (
+	"math/big"
+	"net"
+)
+
+// ServiceIP is an address of the service network that OpenShift assigns to
+// a fixed service.
+type ServiceIP struct {
+	Name    string `json:"name"`
+	IP      string `json:"ip"`
+	Purpose string `json:"purpose"`
+}
+
+const (
+	kubernetesServiceOffset = 1
+	dnsServiceOffset        = 10
+	minServiceHostBits      = 4
+)
+
+// wellKnownServiceIPs derives the kubernetes API service IP, the first
+// usable address, and the cluster DNS service IP, the tenth address.
+func wellKnownServiceIPs(service NamedNetwork) ([]ServiceIP, error) {
+	_, ipNet, err := net.ParseCIDR(service.CIDR)
+	if err != nil {
+		return nil, err
+	}
+	dnsIP := addIP(ipNet.IP, big.NewInt(dnsServiceOffset))
+	// A /28 (or /124) is the smallest network holding the tenth address
+	// that is not also the IPv4 broadcast address.
+	ones, bits := ipNet.Mask.Size()
+	if ones > bits-minServiceHostBits {
+		return nil, fieldError(service.Name, ErrTooSmall, service.CIDR, "%s %s is too small to contain the cluster DNS service IP %s; it must be a /%d or larger", service.Name, service.CIDR, dnsIP, bits-minServiceHostBits)
+	}
+	return []ServiceIP{
+		{Name: "kubernetes", IP: addIP(ipNet.IP, big.NewInt(kubernetesServiceOffset)).String(), Purpose: "Kubernetes API service (default/kubernetes)"},
+		{Name: "dns-default", IP: dnsIP.String(), Purpose: "cluster DNS service (openshift-dns/dns-default)"},
+	}, nil
+}
@@ -5,12 +5,70 @@ document.getElementById('machineNetwork').value = '192.168.1.0/24';
 
 function isValidCIDR(input) {
     const cidrPattern = /^(?:\d{1,3}\.){3}\d{1,3}\/(1[0-9]|2[0-9]|3[0-2]|[1-9])$/;
-    return cidrPattern.test(input);
+    const cidr6Pattern = /^[0-9a-fA-F:]*:[0-9a-fA-F:.]*\/(12[0-8]|1[01][0-9]|[1-9][0-9]|[1-9])$/;
+    return cidrPattern.test(input) || cidr6Pattern.test(input);
 }
 
 function isValidHostPrefix(hostPrefix) {
     const parsedHostPrefix = parseInt(hostPrefix);
-    return !isNaN(parsedHostPrefix) && parsedHostPrefix >= 1 && parsedHostPrefix <= 32;
+    return !isNaN(parsedHostPrefix) && parsedHostPrefix >= 1 && parsedHostPrefix <= 128;
+}
+
+const host = location.hostname === "localhost" ? "https://onc.netlify.app" : "";
+const url = host + "/.netlify/functions/onc"
+let releases = [];
+
+// The version list and the CNIs of each version come from the release
+// table of the calculator.
+function loadReleases() {
+    fetch(url + "/releases", {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: '{}',
+    })
+        .then(response => response.json())
+        .then(data => {
+            releases = data;
+            const select = document.getElementById('openshiftVersion');
+            select.innerHTML = '';
+            releases.forEach(release => {
+                const option = document.createElement('option');
+                option.value = release.version;
+                option.text = release.latest ? release.version + ' and later' : release.version;
+                select.appendChild(option);
+            });
+            updateCNIs();
+        })
+        .catch(error => {
+            console.log('Error loading OpenShift versions: ' + error);
+        });
+}
+
+// updateCNIs disables the CNIs the selected version does not support.
+function updateCNIs() {
+    const version = document.getElementById('openshiftVersion').value;
+    const release = releases.find(release => release.version === version);
+    if (!release) {
+        return;
+    }
+    const select = document.getElementById('cni');
+    Array.from(select.options).forEach(option => {
+        option.disabled = !release.cnis.includes(option.value);
+    });
+    if (select.selectedOptions[0].disabled) {
+        select.value = Array.from(select.options).find(option => !option.disabled).value;
+    }
+}
+
+loadReleases();
+
+// Counts such as the IPv6 pods-per-node exceed the precision of a Number,
+// so long integers are turned into strings before parsing. JSON strings are
+// matched first so digits inside messages are left alone.
+function parseJSON(text) {
+    return JSON.parse(text.replace(/"(?:[^"\\]|\\.)*"|-?\d{16,}/g, match => match.startsWith('"') ? match : '"' + match + '"'));
 }
 
 function calculateNetwork() {
@@ -19,10 +77,11 @@ function calculateNetwork() {
     const serviceNetwork = document.getElementById('serviceNetwork').value;
     const machineNetwork = document.getElementById('machineNetwork').value;
     const cni = document.getElementById('cni').value;
+    const openshiftVersion = document.getElementById('openshiftVersion').value;
 
     // Simple validation
     if (!isValidHostPrefix(hostPrefix) || !isValidCIDR(clusterNetwork) || !isValidCIDR(serviceNetwork) || !isValidCIDR(machineNetwork)) {
-        alert('Please fill in all fields correctly. Network e.g, 192.168.1.0/24 and HostPrefix from 1 to 32).');
+        alert('Please fill in all fields correctly. Network e.g, 192.168.1.0/24 or fd01::/48 and HostPrefix from 1 to 128).');
         return;
     }
 
@@ -32,12 +91,11 @@ function calculateNetwork() {
         clusterNetwork: clusterNetwork,
         serviceNetwork: serviceNetwork,
         machineNetwork: machineNetwork,
-	cni: cni
+	cni: cni,
+        openshiftVersion: openshiftVersion
     };
 
     // Send the request to the Go server
-    const host = location.hostname === "localhost" ? "https://onc.netlify.app" : "";
-    const url = host + "/.netlify/functions/onc"
     fetch(url, {
         method: 'POST',
         headers: {
@@ -45,7 +103,8 @@ function calculateNetwork() {
         },
         body: JSON.stringify(request),
     })
-        .then(response => response.json())
+        .then(response => response.text())
+        .then(parseJSON)
         .then(data => {
             // Display the response
             document.getElementById('result').innerText = JSON.stringify(data, null, 2);
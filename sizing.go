@@ -0,0 +1,218 @@
+package onc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+)
+
+// SizingRequest states workload targets. GrowthFactor (default 1) scales
+// the node and service counts to leave room to grow; pods-per-node is a
+// density limit and is not scaled.
+type SizingRequest struct {
+	Nodes            int      `json:"nodes"`
+	PodsPerNode      int      `json:"podsPerNode"`
+	Services         int      `json:"services"`
+	Family           IPFamily `json:"family,omitempty"`
+	Cni              string   `json:"cni"`
+	OpenShiftVersion string   `json:"openshiftVersion,omitempty"`
+	GrowthFactor     float64  `json:"growthFactor,omitempty"`
+}
+
+type SizingResult struct {
+	Family         IPFamily    `json:"family"`
+	Cni            string      `json:"cni"`
+	Nodes          int         `json:"nodes"`
+	PodsPerNode    int         `json:"pods-per-node"`
+	Services       int         `json:"services"`
+	HostPrefix     SizedPrefix `json:"host-prefix"`
+	ClusterNetwork SizedPrefix `json:"cluster-network"`
+	ServiceNetwork SizedPrefix `json:"service-network"`
+}
+
+// SizedPrefix is a minimum prefix length with the capacity it gives and why
+// it was chosen.
+type SizedPrefix struct {
+	Prefix    int      `json:"prefix"`
+	Capacity  *big.Int `json:"capacity"`
+	Rationale string   `json:"rationale"`
+}
+
+// Size derives the longest hostPrefix, cluster network prefix and service
+// network prefix that meet the targets, counting the addresses the CNI
+// reserves in every node subnet and the fixed service IPs.
+func Size(request SizingRequest) (*SizingResult, error) {
+	var v validation
+	v.errs = append(v.errs, validateTargets(request.Nodes, request.PodsPerNode)...)
+	if request.Services < 0 {
+		v.errs = append(v.errs, fieldError("services", ErrOutOfRange, request.Services, "services must not be negative"))
+	}
+	growth := request.GrowthFactor
+	if growth == 0 {
+		growth = 1
+	}
+	if growth < 1 {
+		v.errs = append(v.errs, fieldError("growthFactor", ErrOutOfRange, request.GrowthFactor, "growthFactor %g must be at least 1", request.GrowthFactor))
+	}
+	family := request.Family
+	if family == "" {
+		family = IPv4
+	}
+	if family != IPv4 && family != IPv6 {
+		v.errs = append(v.errs, fieldError("family", ErrInvalidValue, family, "Invalid IP family: %s", family))
+	}
+	_, cni, err := lookupProfile(&v, request.OpenShiftVersion, request.Cni)
+	if err != nil {
+		return nil, err
+	}
+	if cni != nil {
+		if err := checkFamily(cni, family); err != nil {
+			v.errs = append(v.errs, err)
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	bits := 8 * net.IPv4len
+	if family == IPv6 {
+		bits = 8 * net.IPv6len
+	}
+	nodes, nodesFit := grow(request.Nodes, growth, bits)
+	services, servicesFit := grow(request.Services, growth, bits)
+	switch {
+	case (!nodesFit || !servicesFit) && growth != 1:
+		return nil, fieldError("growthFactor", ErrOutOfRange, request.GrowthFactor, "growthFactor %g grows the targets beyond the %s address space", request.GrowthFactor, family)
+	case !nodesFit:
+		return nil, fieldError("nodes", ErrOutOfRange, request.Nodes, "%d nodes exceed the %s address space", request.Nodes, family)
+	case !servicesFit:
+		return nil, fieldError("services", ErrOutOfRange, request.Services, "%d services exceed the %s address space", request.Services, family)
+	}
+	result := &SizingResult{
+		Family:      family,
+		Cni:         cni.Name(),
+		Nodes:       nodes,
+		PodsPerNode: request.PodsPerNode,
+		Services:    services,
+	}
+
+	reservations := cni.NodeReservations(family, Request{})
+	reserved := new(big.Int)
+	for _, reservation := range reservations {
+		reserved.Add(reserved, reservation.Count)
+	}
+	if family == IPv6 {
+		result.HostPrefix = SizedPrefix{
+			Prefix:    ipv6HostPrefix,
+			Rationale: fmt.Sprintf("OpenShift requires /%d node subnets for IPv6 cluster networks", ipv6HostPrefix),
+		}
+	} else {
+		perNode := new(big.Int).Add(big.NewInt(int64(request.PodsPerNode)), reserved)
+		result.HostPrefix = SizedPrefix{
+			Prefix: bits - ceilLog2(perNode),
+			Rationale: fmt.Sprintf("%d pods plus %s addresses %s reserves per node need %s addresses, which fit in a /%d",
+				request.PodsPerNode, reserved, cni.Name(), perNode, bits-ceilLog2(perNode)),
+		}
+	}
+	if nodePrefix, err := cni.NodePrefix(family, result.HostPrefix.Prefix, Request{}); err == nil && nodePrefix != result.HostPrefix.Prefix {
+		result.HostPrefix.Rationale += fmt.Sprintf("; %s ignores hostPrefix and allocates /%d blocks by default", cni.Name(), nodePrefix)
+	}
+	nodeSubnet := newAddressBreakdown(new(big.Int).Lsh(big.NewInt(1), uint(bits-result.HostPrefix.Prefix)), reservations)
+	result.HostPrefix.Capacity = nodeSubnet.Allocatable
+
+	nodeBits := ceilLog2(big.NewInt(int64(nodes)))
+	clusterPrefix := result.HostPrefix.Prefix - nodeBits
+	if clusterPrefix < 1 {
+		return nil, fieldError("nodes", ErrOutOfRange, request.Nodes, "%d nodes of /%d subnets do not fit in an %s network", nodes, result.HostPrefix.Prefix, family)
+	}
+	result.ClusterNetwork = SizedPrefix{
+		Prefix:   clusterPrefix,
+		Capacity: new(big.Int).Lsh(big.NewInt(1), uint(nodeBits)),
+		Rationale: fmt.Sprintf("%d nodes%s need %d node subnets of /%d, so the cluster network needs %d more bits: /%d",
+			nodes, growthNote(growth, request.Nodes), 1<<uint(nodeBits), result.HostPrefix.Prefix, nodeBits, clusterPrefix),
+	}
+
+	servicePrefix := bits - minServiceHostBits
+	var serviceBreakdown AddressBreakdown
+	for ; ; servicePrefix-- {
+		_, ipNet, _ := net.ParseCIDR(fmt.Sprintf("%s/%d", zeroIP(family), servicePrefix))
+		serviceBreakdown = serviceAddresses(family, ipNet)
+		if serviceBreakdown.Allocatable.Cmp(big.NewInt(int64(services))) >= 0 {
+			break
+		}
+		if family == IPv6 && servicePrefix <= bits-16 {
+			return nil, fieldError("services", ErrOutOfRange, request.Services, "%d services exceed the %s addresses the service IP allocator uses from an IPv6 range", services, serviceIPAllocatorMax)
+		}
+		if servicePrefix == 1 {
+			return nil, fieldError("services", ErrOutOfRange, request.Services, "%d services do not fit in an %s network", services, family)
+		}
+	}
+	rationale := fmt.Sprintf("%d services%s plus the addresses Kubernetes reserves fit in a /%d", services, growthNote(growth, request.Services), servicePrefix)
+	if servicePrefix == bits-minServiceHostBits {
+		rationale = fmt.Sprintf("a /%d is the smallest service network that holds the cluster DNS service IP", servicePrefix)
+	}
+	result.ServiceNetwork = SizedPrefix{
+		Prefix:    servicePrefix,
+		Capacity:  serviceBreakdown.Allocatable,
+		Rationale: rationale,
+	}
+	return result, nil
+}
+
+// validateTargets checks the node and pods-per-node targets shared by
+// sizing and suggestions.
+func validateTargets(nodes, podsPerNode int) []*FieldError {
+	var errs []*FieldError
+	if nodes <= 0 {
+		errs = append(errs, fieldError("nodes", ErrOutOfRange, nodes, "nodes must be positive"))
+	}
+	if podsPerNode <= 0 {
+		errs = append(errs, fieldError("podsPerNode", ErrOutOfRange, podsPerNode, "podsPerNode must be positive"))
+	}
+	return errs
+}
+
+// lookupProfile resolves the release and CNI of a request, recording
+// problems in v. The CNI is nil when it is unknown.
+func lookupProfile(v *validation, openshiftVersion, cniName string) (Release, CNI, error) {
+	release, err := LookupRelease(openshiftVersion)
+	if err := v.add(err); err != nil {
+		return Release{}, nil, err
+	}
+	if err != nil {
+		release, _ = LookupRelease("")
+	}
+	cni, err := LookupCNI(cniName)
+	if err != nil {
+		return release, nil, v.add(err)
+	}
+	return release, cni, v.add(cni.Supported(release))
+}
+
+// grow scales a target by the growth factor. It reports false when the
+// result exceeds the address space of the family, or an int for IPv6.
+func grow(target int, growth float64, bits int) (int, bool) {
+	if bits > 62 {
+		bits = 62
+	}
+	grown := math.Ceil(float64(target) * growth)
+	if grown > math.Ldexp(1, bits) {
+		return 0, false
+	}
+	return int(grown), true
+}
+
+func growthNote(growth float64, target int) string {
+	if growth == 1 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d grown by %g)", target, growth)
+}
+
+func zeroIP(family IPFamily) string {
+	if family == IPv6 {
+		return "::"
+	}
+	return "0.0.0.0"
+}
@@ -0,0 +1,52 @@
+package onc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSizeOutOfRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		request SizingRequest
+		field   string
+	}{
+		{
+			name:    "services beyond an IPv4 network",
+			request: SizingRequest{Nodes: 3, PodsPerNode: 100, Services: 3000000000},
+			field:   "services",
+		},
+		{
+			name:    "growth beyond the address space",
+			request: SizingRequest{Nodes: 3, PodsPerNode: 100, Services: 100, GrowthFactor: 1e300},
+			field:   "growthFactor",
+		},
+		{
+			name:    "nodes beyond the address space",
+			request: SizingRequest{Nodes: 1 << 40, PodsPerNode: 100},
+			field:   "nodes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Size(tt.request)
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("Size = %+v, %v, want a field error", result, err)
+			}
+			if fieldErr.Field != tt.field || fieldErr.Code != ErrOutOfRange {
+				t.Errorf("got %s %s, want %s %s", fieldErr.Field, fieldErr.Code, tt.field, ErrOutOfRange)
+			}
+		})
+	}
+}
+
+func TestSizeLargestIPv4ServiceNetwork(t *testing.T) {
+	result, err := Size(SizingRequest{Nodes: 3, PodsPerNode: 100, Services: 2000000000})
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+	if result.ServiceNetwork.Prefix != 1 {
+		t.Errorf("service network /%d, want /1", result.ServiceNetwork.Prefix)
+	}
+}
@@ -0,0 +1,214 @@
+package onc
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+const (
+	DefaultNodeSubnetLimit = 100
+	MaxNodeSubnetLimit     = 1000
+)
+
+// NodeSubnetsRequest pages through the node subnets of the request's
+// cluster networks, in request order.
+type NodeSubnetsRequest struct {
+	Request
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+type NodeSubnetList struct {
+	Cni    string       `json:"cni"`
+	Total  *big.Int     `json:"total"`
+	Offset int          `json:"offset"`
+	Limit  int          `json:"limit"`
+	Items  []NodeSubnet `json:"items"`
+}
+
+// NodeSubnet is a node's slice of a cluster network. The pod IP range
+// leaves out the addresses the CNI reserves at fixed offsets; plugins that
+// place their reserved addresses anywhere also use some addresses in it.
+type NodeSubnet struct {
+	Index            int    `json:"index"`
+	ClusterNetwork   string `json:"cluster-network"`
+	CIDR             string `json:"cidr"`
+	FirstPodIP       string `json:"first-pod-ip,omitempty"`
+	LastPodIP        string `json:"last-pod-ip,omitempty"`
+	GatewayIP        string `json:"gateway-ip,omitempty"`
+	ManagementPortIP string `json:"management-port-ip,omitempty"`
+}
+
+// nodeSubnetPool is a cluster network entry resolved to its node subnets.
+type nodeSubnetPool struct {
+	name         string
+	ipNet        *net.IPNet
+	nodePrefix   int
+	count        *big.Int
+	reservations []Reservation
+}
+
+func nodeSubnetPools(request Request) ([]nodeSubnetPool, CNI, error) {
+	var v validation
+	_, cni, err := lookupProfile(&v, request.OpenShiftVersion, request.Cni)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cni == nil {
+		return nil, nil, v.err()
+	}
+	request = applyDefaults(request, cni)
+
+	var pools []nodeSubnetPool
+	for i, clusterNetwork := range request.clusterNetworks() {
+		_, ipNet, err := net.ParseCIDR(clusterNetwork.CIDR)
+		if err != nil {
+			v.errs = append(v.errs, invalidCIDR(fmt.Sprintf("clusterNetwork[%d].cidr", i), clusterNetwork.CIDR))
+			continue
+		}
+		family, _ := cidrFamily(clusterNetwork.CIDR)
+		if err := checkFamily(cni, family); err != nil {
+			v.errs = append(v.errs, err)
+			continue
+		}
+		name := fmt.Sprintf("clusterNetwork[%d]", i)
+		nodePrefix, err := cni.NodePrefix(family, clusterNetwork.HostPrefix, request)
+		if err != nil {
+			if err := v.add(err); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		reservations := cni.NodeReservations(family, request)
+		if err := validateHostPrefix(name, clusterNetwork, family, nodePrefix, reservations, request, cni); err != nil {
+			if err := v.add(err); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		count, err := countSubnets(clusterNetwork.CIDR, nodePrefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		pools = append(pools, nodeSubnetPool{
+			name:         name,
+			ipNet:        ipNet,
+			nodePrefix:   nodePrefix,
+			count:        count,
+			reservations: reservations,
+		})
+	}
+	if err := v.err(); err != nil {
+		return nil, nil, err
+	}
+	return pools, cni, nil
+}
+
+// ListNodeSubnets returns a page of the node subnets the cluster networks
+// are split into, with the addresses of interest in each.
+func ListNodeSubnets(request NodeSubnetsRequest) (*NodeSubnetList, error) {
+	if request.Offset < 0 {
+		return nil, fieldError("offset", ErrOutOfRange, request.Offset, "offset %d must not be negative", request.Offset)
+	}
+	limit := request.Limit
+	if limit == 0 {
+		limit = DefaultNodeSubnetLimit
+	}
+	if limit < 0 || limit > MaxNodeSubnetLimit {
+		return nil, fieldError("limit", ErrOutOfRange, request.Limit, "limit %d must be between 1 and %d", request.Limit, MaxNodeSubnetLimit)
+	}
+
+	pools, cni, err := nodeSubnetPools(request.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &NodeSubnetList{
+		Cni:    cni.Name(),
+		Total:  new(big.Int),
+		Offset: request.Offset,
+		Limit:  limit,
+		Items:  []NodeSubnet{},
+	}
+	for _, pool := range pools {
+		list.Total.Add(list.Total, pool.count)
+	}
+
+	// skip counts the subnets before the page that are still to be passed.
+	skip := big.NewInt(int64(request.Offset))
+	index := request.Offset
+	for _, pool := range pools {
+		if len(list.Items) == limit {
+			break
+		}
+		if skip.Cmp(pool.count) >= 0 {
+			skip.Sub(skip, pool.count)
+			continue
+		}
+		for n := new(big.Int).Set(skip); n.Cmp(pool.count) < 0 && len(list.Items) < limit; n.Add(n, big.NewInt(1)) {
+			list.Items = append(list.Items, pool.nodeSubnet(index, n))
+			index++
+		}
+		skip.SetInt64(0)
+	}
+	return list, nil
+}
+
+func (pool nodeSubnetPool) nodeSubnet(index int, n *big.Int) NodeSubnet {
+	subnet := nthSubnet(pool.ipNet, pool.nodePrefix, n)
+	first, last := podRange(pool.reservations, cidrSize(subnet))
+	nodeSubnet := NodeSubnet{
+		Index:          index,
+		ClusterNetwork: pool.name,
+		CIDR:           subnet.String(),
+	}
+	if first.Cmp(last) <= 0 {
+		nodeSubnet.FirstPodIP = addIP(subnet.IP, first).String()
+		nodeSubnet.LastPodIP = addIP(subnet.IP, last).String()
+	}
+	for _, reservation := range pool.reservations {
+		if reservation.Offset == nil {
+			continue
+		}
+		ip := addIP(subnet.IP, reservationOffset(reservation, cidrSize(subnet))).String()
+		switch reservation.Role {
+		case AddressGateway:
+			nodeSubnet.GatewayIP = ip
+		case AddressManagementPort:
+			nodeSubnet.ManagementPortIP = ip
+		}
+	}
+	return nodeSubnet
+}
+
+// reservationOffset resolves a fixed reservation to an offset from the
+// start of a subnet of the given size.
+func reservationOffset(reservation Reservation, size *big.Int) *big.Int {
+	offset := big.NewInt(int64(*reservation.Offset))
+	if offset.Sign() < 0 {
+		offset.Add(offset, size)
+	}
+	return offset
+}
+
+// podRange returns the offsets of the first and last addresses not taken by
+// a fixed reservation at either end of a subnet; first is past last when
+// every address is reserved.
+func podRange(reservations []Reservation, size *big.Int) (first, last *big.Int) {
+	reserved := make(map[string]bool)
+	for _, reservation := range reservations {
+		if reservation.Offset != nil {
+			reserved[reservationOffset(reservation, size).String()] = true
+		}
+	}
+	first = new(big.Int)
+	for first.Cmp(size) < 0 && reserved[first.String()] {
+		first.Add(first, big.NewInt(1))
+	}
+	last = new(big.Int).Sub(size, big.NewInt(1))
+	for last.Cmp(first) > 0 && reserved[last.String()] {
+		last.Sub(last, big.NewInt(1))
+	}
+	return first, last
+}
@@ -0,0 +1,83 @@
+package onc
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// twoPoolRequest has four /24 node subnets in its first cluster network
+// entry and four /25 ones in its second.
+var twoPoolRequest = Request{
+	ClusterNetworks: []ClusterNetworkEntry{{CIDR: "10.128.0.0/22", HostPrefix: 24}, {CIDR: "10.132.0.0/23", HostPrefix: 25}},
+	ServiceNetworks: []string{"172.30.0.0/16"},
+	MachineNetworks: []string{"10.0.0.0/16"},
+}
+
+func TestListNodeSubnets(t *testing.T) {
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          []string
+	}{
+		{
+			name:  "first page",
+			limit: 2,
+			want:  []string{"0 clusterNetwork[0] 10.128.0.0/24", "1 clusterNetwork[0] 10.128.1.0/24"},
+		},
+		{
+			name:   "across the entries",
+			offset: 3,
+			limit:  3,
+			want:   []string{"3 clusterNetwork[0] 10.128.3.0/24", "4 clusterNetwork[1] 10.132.0.0/25", "5 clusterNetwork[1] 10.132.0.128/25"},
+		},
+		{
+			name:   "second entry only",
+			offset: 5,
+			limit:  10,
+			want:   []string{"5 clusterNetwork[1] 10.132.0.128/25", "6 clusterNetwork[1] 10.132.1.0/25", "7 clusterNetwork[1] 10.132.1.128/25"},
+		},
+		{
+			name:   "past the end",
+			offset: 8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := ListNodeSubnets(NodeSubnetsRequest{Request: twoPoolRequest, Offset: tt.offset, Limit: tt.limit})
+			if err != nil {
+				t.Fatalf("ListNodeSubnets: %v", err)
+			}
+			if list.Total.String() != "8" {
+				t.Errorf("total = %s, want 8", list.Total)
+			}
+			var got []string
+			for _, item := range list.Items {
+				got = append(got, strings.Join([]string{strconv.Itoa(item.Index), item.ClusterNetwork, item.CIDR}, " "))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListNodeSubnetsAddresses(t *testing.T) {
+	list, err := ListNodeSubnets(NodeSubnetsRequest{Request: twoPoolRequest, Offset: 4, Limit: 1})
+	if err != nil {
+		t.Fatalf("ListNodeSubnets: %v", err)
+	}
+	got := list.Items[0]
+	want := NodeSubnet{
+		Index:            4,
+		ClusterNetwork:   "clusterNetwork[1]",
+		CIDR:             "10.132.0.0/25",
+		FirstPodIP:       "10.132.0.4",
+		LastPodIP:        "10.132.0.126",
+		GatewayIP:        "10.132.0.1",
+		ManagementPortIP: "10.132.0.2",
+	}
+	if got != want {
+		t.Errorf("node subnet = %+v, want %+v", got, want)
+	}
+}
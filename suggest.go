@@ -0,0 +1,289 @@
+package onc
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+)
+
+// SuggestRequest describes a cluster to fit into the free private address
+// space: ranges in Avoid and the machine network are never used.
+type SuggestRequest struct {
+	MachineNetwork   string   `json:"machineNetwork"`
+	Avoid            []string `json:"avoid"`
+	Nodes            int      `json:"nodes"`
+	PodsPerNode      int      `json:"podsPerNode"`
+	Cni              string   `json:"cni"`
+	OpenShiftVersion string   `json:"openshiftVersion,omitempty"`
+}
+
+// Suggestion is a conflict-free set of networks. Headroom is the pod
+// capacity beyond the target, as a fraction of the target. For CNIs that
+// allocate blocks, such as Calico, Nodes counts blocks and PodsPerNode is
+// the kubelet maxPods default.
+type Suggestion struct {
+	ClusterNetwork string   `json:"clusterNetwork"`
+	HostPrefix     int      `json:"hostPrefix"`
+	ServiceNetwork string   `json:"serviceNetwork"`
+	Nodes          *big.Int `json:"nodes"`
+	PodsPerNode    *big.Int `json:"pods-per-node"`
+	Headroom       float64  `json:"headroom"`
+}
+
+type SuggestResponse struct {
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+const (
+	// ipv6HostPrefix is the only node subnet size OpenShift accepts for
+	// IPv6 cluster networks.
+	ipv6HostPrefix = 64
+	maxSuggestions = 6
+)
+
+var privateRanges = map[IPFamily]struct{ cluster, service []string }{
+	IPv4: {
+		cluster: []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+		service: []string{"172.16.0.0/12", "10.0.0.0/8", "192.168.0.0/16"},
+	},
+	IPv6: {
+		cluster: []string{"fd00::/8"},
+		service: []string{"fd00::/8"},
+	},
+}
+
+var defaultServicePrefix = map[IPFamily]int{
+	IPv4: 16,
+	IPv6: 112,
+}
+
+// Suggest proposes networks from RFC 1918 (or ULA for IPv6) space that meet
+// the node and pods-per-node targets without overlapping the machine
+// network, the avoided ranges, the CNI internal subnets or the commonly
+// clashing ranges CalculateNetwork warns about. The smallest
+// combination comes first; alternatives with bigger node subnets or more
+// nodes follow in order of increasing headroom.
+func Suggest(request SuggestRequest) (*SuggestResponse, error) {
+	var v validation
+	v.errs = append(v.errs, validateTargets(request.Nodes, request.PodsPerNode)...)
+	family, err := cidrFamily(request.MachineNetwork)
+	if err != nil {
+		v.errs = append(v.errs, invalidCIDR("machineNetwork", request.MachineNetwork))
+	}
+	for i, cidr := range request.Avoid {
+		if !isValidCIDR(cidr) {
+			v.errs = append(v.errs, invalidCIDR(fmt.Sprintf("avoid[%d]", i), cidr))
+		}
+	}
+	release, cni, err := lookupProfile(&v, request.OpenShiftVersion, request.Cni)
+	if err != nil {
+		return nil, err
+	}
+	if cni != nil && family != "" {
+		if err := checkFamily(cni, family); err != nil {
+			v.errs = append(v.errs, err)
+		}
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	_, machineIPNet, _ := net.ParseCIDR(request.MachineNetwork)
+	if machine := machineAddresses(family, machineIPNet); machine.Allocatable.Cmp(big.NewInt(int64(request.Nodes))) < 0 {
+		return nil, fieldError("machineNetwork", ErrTooSmall, request.MachineNetwork, "machine network %s holds only %s nodes", request.MachineNetwork, machine.Allocatable)
+	}
+
+	avoid := append([]string{request.MachineNetwork}, request.Avoid...)
+	internalSubnets, err := cni.InternalSubnets(family, Request{}, release)
+	if err != nil {
+		return nil, err
+	}
+	for _, subnet := range internalSubnets {
+		avoid = append(avoid, subnet.CIDR)
+	}
+	for _, known := range knownRanges {
+		if knownFamily, _ := cidrFamily(known.CIDR); knownFamily == family {
+			avoid = append(avoid, known.CIDR)
+		}
+	}
+	avoidNets, err := parseCIDRs(avoid)
+	if err != nil {
+		return nil, err
+	}
+
+	sizing, err := Size(SizingRequest{
+		Nodes:            request.Nodes,
+		PodsPerNode:      request.PodsPerNode,
+		Family:           family,
+		Cni:              request.Cni,
+		OpenShiftVersion: request.OpenShiftVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	hostPrefix := sizing.HostPrefix.Prefix
+	nodeBits := hostPrefix - sizing.ClusterNetwork.Prefix
+
+	response := &SuggestResponse{Suggestions: []Suggestion{}}
+	for _, p := range candidatePrefixes(family, hostPrefix, nodeBits, allocatesBlocks(cni)) {
+		suggestion, err := suggestNetworks(request, family, p.host, p.cluster, avoidNets, cni)
+		if err != nil {
+			return nil, err
+		}
+		if suggestion != nil {
+			response.Suggestions = append(response.Suggestions, *suggestion)
+		}
+	}
+	if len(response.Suggestions) == 0 {
+		return nil, fieldError("nodes", ErrTooSmall, request.Nodes, "no free %s range fits %d nodes with %d pods each", family, request.Nodes, request.PodsPerNode)
+	}
+	sort.SliceStable(response.Suggestions, func(i, j int) bool {
+		return response.Suggestions[i].Headroom < response.Suggestions[j].Headroom
+	})
+	if len(response.Suggestions) > maxSuggestions {
+		response.Suggestions = response.Suggestions[:maxSuggestions]
+	}
+	return response, nil
+}
+
+type prefixPair struct{ host, cluster int }
+
+// candidatePrefixes returns the smallest fitting prefixes followed by
+// alternatives with up to two sizes bigger node subnets and twice the
+// nodes. IPv6 node subnets are always /64, and CNIs that allocate blocks
+// ignore the hostPrefix, so only the cluster network grows for them.
+func candidatePrefixes(family IPFamily, hostPrefix, nodeBits int, blocks bool) []prefixPair {
+	var pairs []prefixPair
+	for dh := 0; dh <= 2; dh++ {
+		if (family == IPv6 || blocks) && dh > 0 {
+			break
+		}
+		for dc := 0; dc <= 1; dc++ {
+			host := hostPrefix - dh
+			cluster := host - nodeBits - dc
+			if cluster < 1 {
+				continue
+			}
+			pairs = append(pairs, prefixPair{host: host, cluster: cluster})
+		}
+	}
+	return pairs
+}
+
+// suggestNetworks places a cluster network and a default-sized service
+// network in free private space and checks the result with
+// CalculateNetwork. It returns nil when either does not fit. Blocks of a
+// block-allocating CNI are shared by the pods of a node, so the cluster
+// network only needs a block per node and the total pod capacity.
+func suggestNetworks(request SuggestRequest, family IPFamily, hostPrefix, clusterPrefix int, avoid []*net.IPNet, cni CNI) (*Suggestion, error) {
+	cluster := findFreeBlock(privateRanges[family].cluster, clusterPrefix, avoid)
+	if cluster == nil {
+		return nil, nil
+	}
+	avoid = append(avoid[:len(avoid):len(avoid)], cluster)
+	service := findFreeBlock(privateRanges[family].service, defaultServicePrefix[family], avoid)
+	if service == nil {
+		return nil, nil
+	}
+
+	result, err := CalculateNetwork(Request{
+		HostPrefix:       hostPrefix,
+		ClusterNetwork:   cluster.String(),
+		ServiceNetwork:   service.String(),
+		MachineNetwork:   request.MachineNetwork,
+		Cni:              request.Cni,
+		OpenShiftVersion: request.OpenShiftVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Conflicts) > 0 {
+		return nil, nil
+	}
+	nodes := result.NumNodes.Want
+	target := new(big.Int).Mul(big.NewInt(int64(request.Nodes)), big.NewInt(int64(request.PodsPerNode)))
+	capacity := new(big.Int).Mul(nodes, result.PodsPerNode)
+	fits := result.PodsPerNode.Cmp(big.NewInt(int64(request.PodsPerNode))) >= 0
+	if allocatesBlocks(cni) {
+		capacity = result.NumPods
+		fits = capacity.Cmp(target) >= 0
+	}
+	if nodes.Cmp(big.NewInt(int64(request.Nodes))) < 0 || !fits {
+		return nil, nil
+	}
+
+	headroom, _ := new(big.Rat).SetFrac(new(big.Int).Sub(capacity, target), target).Float64()
+
+	return &Suggestion{
+		ClusterNetwork: cluster.String(),
+		HostPrefix:     hostPrefix,
+		ServiceNetwork: service.String(),
+		Nodes:          nodes,
+		PodsPerNode:    result.PodsPerNode,
+		Headroom:       headroom,
+	}, nil
+}
+
+// findFreeBlock returns the first /prefix block of the ranges that overlaps
+// none of avoid, skipping past each blocking range instead of stepping
+// through every block.
+func findFreeBlock(ranges []string, prefix int, avoid []*net.IPNet) *net.IPNet {
+	for _, cidr := range ranges {
+		_, pool, _ := net.ParseCIDR(cidr)
+		ones, bits := pool.Mask.Size()
+		if prefix < ones || prefix > bits {
+			continue
+		}
+		size := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefix))
+		end := new(big.Int).Add(ipToInt(pool.IP), cidrSize(pool))
+		for candidate := ipToInt(pool.IP); new(big.Int).Add(candidate, size).Cmp(end) <= 0; {
+			block := &net.IPNet{IP: intToIP(candidate, len(pool.IP)), Mask: net.CIDRMask(prefix, bits)}
+			var blocker *net.IPNet
+			for _, a := range avoid {
+				if overlapCIDR(block, a) != nil {
+					blocker = a
+					break
+				}
+			}
+			if blocker == nil {
+				return block
+			}
+			next := new(big.Int).Add(ipToInt(blocker.IP), cidrSize(blocker))
+			next = alignUp(next, size)
+			if next.Cmp(candidate) <= 0 {
+				next.Add(candidate, size)
+			}
+			candidate = next
+		}
+	}
+	return nil
+}
+
+func alignUp(n, size *big.Int) *big.Int {
+	rem := new(big.Int).Mod(n, size)
+	if rem.Sign() == 0 {
+		return n
+	}
+	return n.Add(n, new(big.Int).Sub(size, rem))
+}
+
+// ceilLog2 returns the number of bits needed to count n values.
+func ceilLog2(n *big.Int) int {
+	if n.Cmp(big.NewInt(1)) <= 0 {
+		return 0
+	}
+	return new(big.Int).Sub(n, big.NewInt(1)).BitLen()
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var ipNets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return ipNets, nil
+}
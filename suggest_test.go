@@ -0,0 +1,41 @@
+package onc
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSuggestAvoidsKnownRanges(t *testing.T) {
+	response, err := Suggest(SuggestRequest{MachineNetwork: "172.16.0.0/16", Nodes: 100, PodsPerNode: 250})
+	if err != nil {
+		t.Fatalf("Suggest: %v", err)
+	}
+	if len(response.Suggestions) == 0 {
+		t.Fatal("no suggestions")
+	}
+	for _, suggestion := range response.Suggestions {
+		for _, cidr := range []string{suggestion.ClusterNetwork, suggestion.ServiceNetwork} {
+			_, ipNet, _ := net.ParseCIDR(cidr)
+			for _, known := range knownRanges {
+				_, knownNet, _ := net.ParseCIDR(known.CIDR)
+				if overlapCIDR(ipNet, knownNet) != nil {
+					t.Errorf("suggested %s overlaps the %s range %s", cidr, known.Name, known.CIDR)
+				}
+			}
+		}
+		result, err := CalculateNetwork(Request{
+			ClusterNetwork: suggestion.ClusterNetwork,
+			HostPrefix:     suggestion.HostPrefix,
+			ServiceNetwork: suggestion.ServiceNetwork,
+			MachineNetwork: "172.16.0.0/16",
+		})
+		if err != nil {
+			t.Fatalf("CalculateNetwork(%+v): %v", suggestion, err)
+		}
+		for _, warning := range result.Warnings {
+			if warning.Code == CodeKnownRangeOverlap {
+				t.Errorf("suggestion %s/%s: %s", suggestion.ClusterNetwork, suggestion.ServiceNetwork, warning.Message)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package onc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Release holds the calculator rules that changed between OpenShift
+// releases. A table entry applies from its Minor release until the next one.
+type Release struct {
+	// Version is the normalised "4.<minor>" version, empty when the
+	// request did not name one and the newest rules apply.
+	Version string
+	Minor   int
+
+	// OpenShiftSDN reports whether new clusters may use openshift-sdn.
+	OpenShiftSDN bool
+	// Kuryr reports whether new clusters on OpenStack may use kuryr.
+	Kuryr bool
+	// TransitSwitch reports whether OVN-Kubernetes runs in interconnect
+	// mode, which adds the transit switch subnet.
+	TransitSwitch bool
+	OVNSubnets    map[IPFamily]OVNSubnets
+}
+
+// OVNSubnets are the default OVN-Kubernetes internal subnets of a family.
+type OVNSubnets struct {
+	Join       string
+	Transit    string
+	Masquerade string
+}
+
+const (
+	minMinorVersion     = 10
+	sdnRemovedVersion   = 17
+	kuryrRemovedVersion = 15
+	transitSwitchSince  = 14
+)
+
+var releases = []Release{
+	{
+		Minor:        minMinorVersion,
+		OpenShiftSDN: true,
+		Kuryr:        true,
+		OVNSubnets: map[IPFamily]OVNSubnets{
+			IPv4: {Join: "100.64.0.0/16", Masquerade: "169.254.169.0/29"},
+			IPv6: {Join: "fd98::/64", Masquerade: "fd69::/125"},
+		},
+	},
+	{
+		Minor:         transitSwitchSince,
+		OpenShiftSDN:  true,
+		Kuryr:         true,
+		TransitSwitch: true,
+		OVNSubnets: map[IPFamily]OVNSubnets{
+			IPv4: {Join: "100.64.0.0/16", Transit: "100.88.0.0/16", Masquerade: "169.254.169.0/29"},
+			IPv6: {Join: "fd98::/64", Transit: "fd97::/64", Masquerade: "fd69::/125"},
+		},
+	},
+	{
+		Minor:         kuryrRemovedVersion,
+		OpenShiftSDN:  true,
+		TransitSwitch: true,
+		OVNSubnets: map[IPFamily]OVNSubnets{
+			IPv4: {Join: "100.64.0.0/16", Transit: "100.88.0.0/16", Masquerade: "169.254.169.0/29"},
+			IPv6: {Join: "fd98::/64", Transit: "fd97::/64", Masquerade: "fd69::/125"},
+		},
+	},
+	{
+		Minor:         sdnRemovedVersion,
+		TransitSwitch: true,
+		OVNSubnets: map[IPFamily]OVNSubnets{
+			IPv4: {Join: "100.64.0.0/16", Transit: "100.88.0.0/16", Masquerade: "169.254.0.0/17"},
+			IPv6: {Join: "fd98::/64", Transit: "fd97::/64", Masquerade: "fd69::/112"},
+		},
+	},
+}
+
+// ReleaseSupport lists the CNIs new clusters of a release may use. Latest
+// marks the newest rules, which also apply to later releases.
+type ReleaseSupport struct {
+	Version string   `json:"version"`
+	Latest  bool     `json:"latest,omitempty"`
+	CNIs    []string `json:"cnis"`
+}
+
+// SupportedReleases lists every release the calculator covers, newest
+// first, up to the first release of the newest rules.
+func SupportedReleases() []ReleaseSupport {
+	newest := releases[len(releases)-1].Minor
+	var supported []ReleaseSupport
+	for minor := newest; minor >= minMinorVersion; minor-- {
+		release, _ := LookupRelease(fmt.Sprintf("4.%d", minor))
+		support := ReleaseSupport{Version: release.Version, Latest: minor == newest, CNIs: []string{}}
+		for _, name := range CNINames() {
+			if cnis[name].Supported(release) == nil {
+				support.CNIs = append(support.CNIs, name)
+			}
+		}
+		supported = append(supported, support)
+	}
+	return supported
+}
+
+// LookupRelease returns the rules for an OpenShift version such as "4.16"
+// or "4.16.3". An empty version selects the newest rules.
+func LookupRelease(openshiftVersion string) (Release, error) {
+	if openshiftVersion == "" {
+		return releases[len(releases)-1], nil
+	}
+	minor, err := parseMinorVersion(openshiftVersion)
+	if err != nil {
+		return Release{}, err
+	}
+	if minor < minMinorVersion {
+		return Release{}, fieldError("openshiftVersion", ErrUnsupported, openshiftVersion, "OpenShift %s is not supported; the calculator covers 4.%d and later", openshiftVersion, minMinorVersion)
+	}
+	release := releases[0]
+	for _, r := range releases {
+		if r.Minor <= minor {
+			release = r
+		}
+	}
+	release.Version = fmt.Sprintf("4.%d", minor)
+	release.Minor = minor
+	return release, nil
+}
+
+func parseMinorVersion(openshiftVersion string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(openshiftVersion, "v"), ".")
+	if len(parts) < 2 || parts[0] != "4" {
+		return 0, fieldError("openshiftVersion", ErrInvalidValue, openshiftVersion, "Invalid OpenShift version: %s", openshiftVersion)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil || minor < 0 {
+		return 0, fieldError("openshiftVersion", ErrInvalidValue, openshiftVersion, "Invalid OpenShift version: %s", openshiftVersion)
+	}
+	return minor, nil
+}